@@ -0,0 +1,299 @@
+package chain
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RecoverOptions configures Recover.
+type RecoverOptions struct {
+	// Handler writes the response after a panic, if none was started. It
+	// defaults to a 500 Internal Server Error.
+	Handler func(w http.ResponseWriter, r *http.Request, err any)
+	// Log receives the panic value and the stack trace. It defaults to
+	// log.Printf.
+	Log func(r *http.Request, err any, stack []byte)
+}
+
+// Recover returns a Constructor which recovers from panics in the next
+// handlers, logs them and replies with an error instead of dropping the
+// connection. http.ErrAbortHandler is left to net/http. opts may be nil.
+//
+// Put it after AccessLog so that the error response gets logged:
+//
+//	chain.New(chain.AccessLog(nil), chain.Recover(nil)).Then(app)
+func Recover(opts *RecoverOptions) Constructor {
+	var o RecoverOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Handler == nil {
+		o.Handler = func(w http.ResponseWriter, r *http.Request, err any) {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+	}
+	if o.Log == nil {
+		o.Log = func(r *http.Request, err any, stack []byte) {
+			log.Printf("chain: panic serving %s %s: %v\n%s", r.Method, r.URL.RequestURI(), err, stack)
+		}
+	}
+
+	return ConstructorFunc(func(w http.ResponseWriter, r *http.Request, next http.Handler) {
+		rw := WrapResponseWriter(w)
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
+			o.Log(r, err, debug.Stack())
+			if !rw.Written() && !rw.Hijacked() {
+				o.Handler(rw, r, err)
+			}
+		}()
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// AccessLogEntry describes a request served, see AccessLog.
+type AccessLogEntry struct {
+	Time       time.Time // when the request started
+	Method     string
+	URI        string // the request URI, with the query string
+	Proto      string
+	RemoteAddr string
+	Host       string
+	UserAgent  string
+	Referer    string
+	RequestID  string // set by RequestID, if used before AccessLog
+	Status     int
+	Bytes      int64 // the size of the response body
+	Duration   time.Duration
+	TTFB       time.Duration // the time to first byte, see ResponseWriter
+}
+
+// String formats the entry as logfmt key=value pairs.
+func (e AccessLogEntry) String() string {
+	var b strings.Builder
+	field := func(key, value string) {
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(key)
+		b.WriteByte('=')
+		if value == "" || strings.ContainsAny(value, " \"=\\") || strings.IndexFunc(value, isControl) >= 0 {
+			b.WriteString(strconv.Quote(value))
+		} else {
+			b.WriteString(value)
+		}
+	}
+	field("time", e.Time.Format(time.RFC3339Nano))
+	field("method", e.Method)
+	field("uri", e.URI)
+	field("proto", e.Proto)
+	field("remote", e.RemoteAddr)
+	field("host", e.Host)
+	field("status", strconv.Itoa(e.Status))
+	field("bytes", strconv.FormatInt(e.Bytes, 10))
+	field("duration", e.Duration.String())
+	field("ttfb", e.TTFB.String())
+	field("user_agent", e.UserAgent)
+	field("referer", e.Referer)
+	if e.RequestID != "" {
+		field("request_id", e.RequestID)
+	}
+	return b.String()
+}
+
+func isControl(r rune) bool {
+	return r < ' ' || r == 0x7f
+}
+
+// AccessLog returns a Constructor which passes an AccessLogEntry to fn once
+// each request is served. A nil fn logs the entries with log.Print.
+// A request whose handler panics is logged with a 500 status, unless a
+// response was started, and the panic carries on to the outer handlers.
+//
+//	logger := log.New(os.Stdout, "", 0)
+//	chain.AccessLog(func(e chain.AccessLogEntry) {
+//	    if e.Status >= 400 {
+//	        logger.Print(e)
+//	    }
+//	})
+func AccessLog(fn func(AccessLogEntry)) Constructor {
+	if fn == nil {
+		fn = func(e AccessLogEntry) { log.Print(e) }
+	}
+	return ConstructorFunc(func(w http.ResponseWriter, r *http.Request, next http.Handler) {
+		start := time.Now()
+		rw := WrapResponseWriter(w)
+		returned := false
+		defer func() {
+			status := rw.Status()
+			switch {
+			case status != 0:
+			case !returned:
+				// panicking, without recovering to keep the stack trace
+				// for Recover or net/http
+				status = http.StatusInternalServerError
+			default:
+				// net/http sends a 200 for handlers which write nothing
+				status = http.StatusOK
+			}
+			fn(AccessLogEntry{
+				Time:       start,
+				Method:     r.Method,
+				URI:        r.URL.RequestURI(),
+				Proto:      r.Proto,
+				RemoteAddr: r.RemoteAddr,
+				Host:       r.Host,
+				UserAgent:  r.UserAgent(),
+				Referer:    r.Referer(),
+				RequestID:  RequestIDFromContext(r.Context()),
+				Status:     status,
+				Bytes:      rw.BytesWritten(),
+				Duration:   time.Since(start),
+				TTFB:       rw.TimeToFirstByte(),
+			})
+		}()
+		next.ServeHTTP(rw, r)
+		returned = true
+	})
+}
+
+type contextKey int
+
+const requestIDKey contextKey = 0
+
+// RequestIDOptions configures RequestID.
+type RequestIDOptions struct {
+	// Header carries the ID in requests and responses. It defaults to
+	// X-Request-Id.
+	Header string
+	// Generate returns a new ID. It defaults to 16 random bytes in hex.
+	Generate func() string
+	// IgnoreIncoming always generates a new ID instead of keeping the one
+	// sent by the client, e.g. for servers exposed to the Internet.
+	IgnoreIncoming bool
+}
+
+// RequestID returns a Constructor which gives every request an ID, taken
+// from the request's header when it has a valid one, or generated. The ID
+// is set in the request's context, see RequestIDFromContext, in the request
+// header for proxied calls, and in the response header. opts may be nil.
+func RequestID(opts *RequestIDOptions) Constructor {
+	var o RequestIDOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Header == "" {
+		o.Header = "X-Request-Id"
+	}
+	if o.Generate == nil {
+		o.Generate = newRequestID
+	}
+
+	return ConstructorFunc(func(w http.ResponseWriter, r *http.Request, next http.Handler) {
+		id := r.Header.Get(o.Header)
+		if o.IgnoreIncoming || !validRequestID(id) {
+			id = o.Generate()
+		}
+		r = r.WithContext(WithRequestID(r.Context(), id))
+		r.Header.Set(o.Header, id)
+		w.Header().Set(o.Header, id)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// WithRequestID returns a copy of ctx carrying a request ID.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestIDFromContext returns the request ID set by RequestID, or "".
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// unique enough to correlate logs
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b)
+}
+
+// validRequestID keeps client IDs short and printable, as they end up in logs.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] >= 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// TimeoutOptions configures Timeout.
+type TimeoutOptions struct {
+	// Message is the body of the 503 Service Unavailable sent on timeout.
+	// It defaults to "Service Unavailable".
+	Message string
+	// ContextOnly only sets the deadline of the request's context, leaving
+	// it to the handlers to give up and reply. Otherwise, the response is
+	// buffered like with http.TimeoutHandler and replaced by a 503 if the
+	// handlers don't return in time, which prevents streaming and hijacking.
+	ContextOnly bool
+}
+
+// Timeout returns a Constructor which limits the time spent serving each
+// request to d. opts may be nil.
+func Timeout(d time.Duration, opts *TimeoutOptions) Constructor {
+	var o TimeoutOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.ContextOnly {
+		return ConstructorFunc(func(w http.ResponseWriter, r *http.Request, next http.Handler) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+	if o.Message == "" {
+		o.Message = http.StatusText(http.StatusServiceUnavailable)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.TimeoutHandler(next, d, o.Message)
+	}
+}
+
+// BodyLimit returns a Constructor which limits the size of request bodies to
+// n bytes. Requests announcing a larger Content-Length get a 413 Request
+// Entity Too Large right away; otherwise reading past the limit fails, and
+// the connection is closed after the response.
+func BodyLimit(n int64) Constructor {
+	return ConstructorFunc(func(w http.ResponseWriter, r *http.Request, next http.Handler) {
+		if r.ContentLength > n {
+			http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+			return
+		}
+		if r.Body != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, n)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
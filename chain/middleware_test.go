@@ -0,0 +1,232 @@
+package chain_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Akagi201/utils-go/chain"
+)
+
+func TestRecover(t *testing.T) {
+	var logged any
+	var stack []byte
+	recoverer := chain.Recover(&chain.RecoverOptions{
+		Log: func(r *http.Request, err any, s []byte) { logged, stack = err, s },
+	})
+	h := chain.New(recoverer).ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected a 500 but got %d", w.Code)
+	}
+	if logged != "boom" || !strings.Contains(string(stack), "middleware_test.go") {
+		t.Errorf("Unexpected log %v\n%s", logged, stack)
+	}
+
+	// a response already started is left alone
+	h = chain.New(recoverer).ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		panic("late")
+	})
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusAccepted || logged != "late" {
+		t.Errorf("Unexpected status %d, log %v", w.Code, logged)
+	}
+
+	defer func() {
+		if err := recover(); err != http.ErrAbortHandler {
+			t.Errorf("Expected http.ErrAbortHandler to be panicked again but got %v", err)
+		}
+	}()
+	h = chain.New(recoverer).ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+}
+
+func TestAccessLog(t *testing.T) {
+	var entry chain.AccessLogEntry
+	h := chain.New(
+		chain.RequestID(&chain.RequestIDOptions{Generate: func() string { return "id-1" }}),
+		chain.AccessLog(func(e chain.AccessLogEntry) { entry = e }),
+	).ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, "not here")
+	})
+
+	r := httptest.NewRequest("GET", "/a?b=c", nil)
+	r.Header.Set("User-Agent", "test agent")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	if entry.Method != "GET" || entry.URI != "/a?b=c" || entry.Status != http.StatusNotFound ||
+		entry.Bytes != 8 || entry.RequestID != "id-1" || entry.Time.IsZero() {
+		t.Errorf("Unexpected entry %+v", entry)
+	}
+	s := entry.String()
+	for _, field := range []string{`method=GET`, `uri="/a?b=c"`, `status=404`, `bytes=8`, `user_agent="test agent"`, `referer=""`, `request_id=id-1`} {
+		if !strings.Contains(s, field) {
+			t.Errorf("Expected %s in %s", field, s)
+		}
+	}
+
+	// an implicit 200
+	h = chain.New(chain.AccessLog(func(e chain.AccessLogEntry) { entry = e })).ThenFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if entry.Status != http.StatusOK || entry.Bytes != 0 {
+		t.Errorf("Unexpected entry %+v", entry)
+	}
+}
+
+func TestAccessLogPanic(t *testing.T) {
+	var entry chain.AccessLogEntry
+	var stack []byte
+	panicking := func(w http.ResponseWriter, r *http.Request) { panic("boom") }
+
+	// Recover inside AccessLog logs the error response
+	h := chain.New(
+		chain.AccessLog(func(e chain.AccessLogEntry) { entry = e }),
+		chain.Recover(&chain.RecoverOptions{Log: func(*http.Request, any, []byte) {}}),
+	).ThenFunc(panicking)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if entry.Status != http.StatusInternalServerError || entry.Bytes == 0 {
+		t.Errorf("Unexpected entry %+v", entry)
+	}
+
+	// Recover outside AccessLog still gets a 500 logged and the whole stack
+	entry = chain.AccessLogEntry{}
+	h = chain.New(
+		chain.Recover(&chain.RecoverOptions{Log: func(_ *http.Request, _ any, s []byte) { stack = s }}),
+		chain.AccessLog(func(e chain.AccessLogEntry) { entry = e }),
+	).ThenFunc(panicking)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if entry.Status != http.StatusInternalServerError || w.Code != http.StatusInternalServerError {
+		t.Errorf("Unexpected entry %+v and status %d", entry, w.Code)
+	}
+	if !strings.Contains(string(stack), "TestAccessLogPanic") {
+		t.Errorf("Expected the stack of the panic but got\n%s", stack)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	var seen string
+	app := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = chain.RequestIDFromContext(r.Context())
+		if r.Header.Get("X-Request-Id") != seen {
+			t.Errorf("Expected the ID in the request header")
+		}
+	})
+
+	h := chain.New(chain.RequestID(nil)).Then(app)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if len(seen) != 32 || w.Header().Get("X-Request-Id") != seen {
+		t.Errorf("Unexpected generated ID %q, response header %q", seen, w.Header().Get("X-Request-Id"))
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Request-Id", "upstream-42")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	if seen != "upstream-42" {
+		t.Errorf("Expected the incoming ID but got %q", seen)
+	}
+
+	r = httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Request-Id", "bad id\n")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	if seen == "bad id\n" || seen == "" {
+		t.Errorf("Expected an invalid ID to be replaced but got %q", seen)
+	}
+
+	h = chain.New(chain.RequestID(&chain.RequestIDOptions{
+		Header:         "X-Trace",
+		Generate:       func() string { return "fixed" },
+		IgnoreIncoming: true,
+	})).ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = chain.RequestIDFromContext(r.Context())
+	})
+	r = httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Trace", "client")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if seen != "fixed" || w.Header().Get("X-Trace") != "fixed" {
+		t.Errorf("Expected the generated ID but got %q", seen)
+	}
+
+	if id := chain.RequestIDFromContext(context.Background()); id != "" {
+		t.Errorf("Expected no ID but got %q", id)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+			io.WriteString(w, "done")
+		case <-r.Context().Done():
+			http.Error(w, "gave up", http.StatusGatewayTimeout)
+		}
+	})
+
+	w := httptest.NewRecorder()
+	chain.New(chain.Timeout(20*time.Millisecond, &chain.TimeoutOptions{Message: "too slow"})).Then(slow).
+		ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusServiceUnavailable || w.Body.String() != "too slow" {
+		t.Errorf("Unexpected response %d %q", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	chain.New(chain.Timeout(20*time.Millisecond, &chain.TimeoutOptions{ContextOnly: true})).Then(slow).
+		ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("Expected the handler's response but got %d %q", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	chain.New(chain.Timeout(time.Second, nil)).ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "fast")
+	}).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "fast" {
+		t.Errorf("Unexpected response %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestBodyLimit(t *testing.T) {
+	h := chain.New(chain.BodyLimit(5)).ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		w.Write(body)
+	})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader("12345")))
+	if w.Code != http.StatusOK || w.Body.String() != "12345" {
+		t.Errorf("Unexpected response %d %q", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader("123456")))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected a 413 for a large Content-Length but got %d", w.Code)
+	}
+
+	// a chunked body, without Content-Length
+	r := httptest.NewRequest("POST", "/", io.MultiReader(strings.NewReader("1234"), strings.NewReader("56")))
+	r.ContentLength = -1
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusRequestEntityTooLarge || !strings.Contains(w.Body.String(), "too large") {
+		t.Errorf("Expected the read to fail but got %d %q", w.Code, w.Body.String())
+	}
+}
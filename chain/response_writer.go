@@ -0,0 +1,168 @@
+package chain
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ResponseWriter is an http.ResponseWriter recording the status, size and
+// timing of the response, see WrapResponseWriter.
+type ResponseWriter interface {
+	http.ResponseWriter
+	// Status returns the status code sent, 0 if none was sent yet.
+	Status() int
+	// Written reports whether the response header was sent.
+	Written() bool
+	// BytesWritten returns the size of the body written so far.
+	BytesWritten() int64
+	// TimeToFirstByte returns the time between the wrapping and the sending
+	// of the header, 0 if it wasn't sent yet.
+	TimeToFirstByte() time.Duration
+	// Hijacked reports whether the connection was taken over with Hijack.
+	Hijacked() bool
+	// Unwrap returns the wrapped http.ResponseWriter, as expected by
+	// http.ResponseController.
+	Unwrap() http.ResponseWriter
+}
+
+// WrapResponseWriter returns a ResponseWriter writing to w. It implements
+// http.Flusher, http.Hijacker and io.ReaderFrom when w does, so wrapping
+// doesn't disable streaming, websockets or sendfile. If w is already a
+// ResponseWriter, it is returned as is, so the middleware of a Chain share
+// the recording of the outermost one.
+//
+//	func timing(w http.ResponseWriter, r *http.Request, next http.Handler) {
+//	    rw := chain.WrapResponseWriter(w)
+//	    next.ServeHTTP(rw, r)
+//	    metrics.Observe(r.URL.Path, rw.Status(), rw.TimeToFirstByte())
+//	}
+func WrapResponseWriter(w http.ResponseWriter) ResponseWriter {
+	if rw, ok := w.(ResponseWriter); ok {
+		return rw
+	}
+	rw := &responseWriter{ResponseWriter: w, start: time.Now()}
+
+	_, isFlusher := w.(http.Flusher)
+	_, isHijacker := w.(http.Hijacker)
+	_, isReaderFrom := w.(io.ReaderFrom)
+	switch {
+	case isFlusher && isHijacker && isReaderFrom:
+		return struct {
+			*responseWriter
+			flusher
+			hijacker
+			readerFrom
+		}{rw, flusher{rw}, hijacker{rw}, readerFrom{rw}}
+	case isFlusher && isHijacker:
+		return struct {
+			*responseWriter
+			flusher
+			hijacker
+		}{rw, flusher{rw}, hijacker{rw}}
+	case isFlusher && isReaderFrom:
+		return struct {
+			*responseWriter
+			flusher
+			readerFrom
+		}{rw, flusher{rw}, readerFrom{rw}}
+	case isHijacker && isReaderFrom:
+		return struct {
+			*responseWriter
+			hijacker
+			readerFrom
+		}{rw, hijacker{rw}, readerFrom{rw}}
+	case isFlusher:
+		return struct {
+			*responseWriter
+			flusher
+		}{rw, flusher{rw}}
+	case isHijacker:
+		return struct {
+			*responseWriter
+			hijacker
+		}{rw, hijacker{rw}}
+	case isReaderFrom:
+		return struct {
+			*responseWriter
+			readerFrom
+		}{rw, readerFrom{rw}}
+	}
+	return rw
+}
+
+// responseWriter implements ResponseWriter without the optional interfaces,
+// which the types below add as needed.
+type responseWriter struct {
+	http.ResponseWriter
+	start    time.Time
+	status   int
+	bytes    int64
+	ttfb     time.Duration
+	hijacked bool
+}
+
+func (rw *responseWriter) WriteHeader(status int) {
+	// informational responses come before the real one
+	if status >= 100 && status < 200 && status != http.StatusSwitchingProtocols {
+		rw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	rw.wroteHeader(status)
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *responseWriter) wroteHeader(status int) {
+	if rw.status == 0 {
+		rw.status = status
+		rw.ttfb = time.Since(rw.start)
+	}
+}
+
+func (rw *responseWriter) Write(p []byte) (int, error) {
+	rw.wroteHeader(http.StatusOK)
+	n, err := rw.ResponseWriter.Write(p)
+	rw.bytes += int64(n)
+	return n, err
+}
+
+func (rw *responseWriter) Status() int                    { return rw.status }
+func (rw *responseWriter) Written() bool                  { return rw.status != 0 }
+func (rw *responseWriter) BytesWritten() int64            { return rw.bytes }
+func (rw *responseWriter) TimeToFirstByte() time.Duration { return rw.ttfb }
+func (rw *responseWriter) Hijacked() bool                 { return rw.hijacked }
+func (rw *responseWriter) Unwrap() http.ResponseWriter    { return rw.ResponseWriter }
+
+type flusher struct {
+	rw *responseWriter
+}
+
+func (f flusher) Flush() {
+	f.rw.wroteHeader(http.StatusOK)
+	f.rw.ResponseWriter.(http.Flusher).Flush()
+}
+
+type hijacker struct {
+	rw *responseWriter
+}
+
+func (h hijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := h.rw.ResponseWriter.(http.Hijacker).Hijack()
+	if err == nil {
+		h.rw.hijacked = true
+	}
+	return conn, brw, err
+}
+
+type readerFrom struct {
+	rw *responseWriter
+}
+
+func (r readerFrom) ReadFrom(src io.Reader) (int64, error) {
+	r.rw.wroteHeader(http.StatusOK)
+	n, err := r.rw.ResponseWriter.(io.ReaderFrom).ReadFrom(src)
+	r.rw.bytes += n
+	return n, err
+}
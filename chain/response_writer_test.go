@@ -0,0 +1,126 @@
+package chain_test
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Akagi201/utils-go/chain"
+)
+
+func TestWrapResponseWriter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rw := chain.WrapResponseWriter(rec)
+	if chain.WrapResponseWriter(rw) != rw {
+		t.Error("Expected a ResponseWriter not to be wrapped again")
+	}
+	if rw.Unwrap() != rec {
+		t.Error("Unwrap doesn't return the wrapped writer")
+	}
+	if _, ok := rw.(http.Flusher); !ok {
+		t.Error("Expected the wrapper to keep http.Flusher")
+	}
+	if _, ok := rw.(http.Hijacker); ok {
+		t.Error("Expected no http.Hijacker for a recorder")
+	}
+	if _, ok := rw.(io.ReaderFrom); ok {
+		t.Error("Expected no io.ReaderFrom for a recorder")
+	}
+
+	if rw.Written() || rw.Status() != 0 {
+		t.Error("Expected nothing written yet")
+	}
+	time.Sleep(10 * time.Millisecond)
+	rw.WriteHeader(http.StatusCreated)
+	rw.Write([]byte("hello"))
+	rw.Write([]byte(" world"))
+	if rw.Status() != http.StatusCreated || rw.BytesWritten() != 11 || rw.TimeToFirstByte() < 10*time.Millisecond {
+		t.Errorf("Unexpected status %d, bytes %d, TTFB %s", rw.Status(), rw.BytesWritten(), rw.TimeToFirstByte())
+	}
+
+	rw = chain.WrapResponseWriter(httptest.NewRecorder())
+	rw.(http.Flusher).Flush()
+	if rw.Status() != http.StatusOK {
+		t.Errorf("Expected Flush to send a 200 but got %d", rw.Status())
+	}
+}
+
+func TestWrapResponseWriterServer(t *testing.T) {
+	results := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := chain.WrapResponseWriter(w)
+		_, isFlusher := rw.(http.Flusher)
+		_, isHijacker := rw.(http.Hijacker)
+		rf, isReaderFrom := rw.(io.ReaderFrom)
+		if !isFlusher || !isHijacker || !isReaderFrom {
+			results <- "missing optional interfaces"
+			return
+		}
+
+		if r.URL.Path == "/hijack" {
+			conn, brw, err := rw.(http.Hijacker).Hijack()
+			if err != nil {
+				results <- err.Error()
+				return
+			}
+			defer conn.Close()
+			brw.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 2\r\nConnection: close\r\n\r\nhi")
+			brw.Flush()
+			if !rw.Hijacked() {
+				results <- "not hijacked"
+				return
+			}
+			results <- ""
+			return
+		}
+
+		n, err := rf.ReadFrom(strings.NewReader("streamed"))
+		if err != nil || n != 8 || rw.BytesWritten() != 8 || rw.Status() != http.StatusOK {
+			results <- "unexpected ReadFrom result"
+			return
+		}
+		results <- ""
+	}))
+	defer srv.Close()
+
+	for _, path := range []string{"/", "/hijack"} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if msg := <-results; msg != "" {
+			t.Errorf("%s: %s", path, msg)
+		}
+		if path == "/hijack" && string(body) != "hi" {
+			t.Errorf("Unexpected hijacked response %q", body)
+		}
+	}
+}
+
+func TestResponseWriterShared(t *testing.T) {
+	var outer, inner chain.ResponseWriter
+	h := chain.New(
+		chain.ConstructorFunc(func(w http.ResponseWriter, r *http.Request, next http.Handler) {
+			outer = chain.WrapResponseWriter(w)
+			next.ServeHTTP(outer, r)
+		}),
+		chain.ConstructorFunc(func(w http.ResponseWriter, r *http.Request, next http.Handler) {
+			inner = chain.WrapResponseWriter(w)
+			next.ServeHTTP(inner, r)
+		}),
+	).ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+		bw := bufio.NewWriter(w)
+		bw.WriteString("body")
+		bw.Flush()
+	})
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if outer != inner || outer.BytesWritten() != 4 {
+		t.Errorf("Expected the middleware to share the writer")
+	}
+}
@@ -0,0 +1,119 @@
+package chain
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// A Predicate decides whether a request goes through a piece of middleware,
+// see When. It only sees the request, as it runs before the response is
+// started.
+type Predicate func(*http.Request) bool
+
+// When returns a Constructor applying c only to the requests matching pred;
+// the others go straight to the next handler.
+//
+//	chain.New(
+//	    chain.AccessLog(nil),
+//	    chain.When(chain.PathPrefix("/api/"), auth),
+//	    chain.When(chain.Methods("POST", "PUT"), chain.BodyLimit(1<<20)),
+//	).Then(mux)
+//
+// A whole chain can be scoped with its Then method:
+//
+//	chain.When(chain.PathPrefix("/admin"), adminChain.Then)
+//
+// Conditions on the response, like compressing only some Content-Types,
+// aren't supported: the choice is made before next runs. Such middleware
+// has to wrap the ResponseWriter and decide in its WriteHeader instead.
+func When(pred Predicate, c Constructor) Constructor {
+	return func(next http.Handler) http.Handler {
+		wrapped := c(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if pred(r) {
+				wrapped.ServeHTTP(w, r)
+			} else {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// Unless returns a Constructor applying c to the requests not matching pred.
+func Unless(pred Predicate, c Constructor) Constructor {
+	return When(Not(pred), c)
+}
+
+// PathPrefix matches the requests whose path is below prefix. A prefix
+// without a trailing slash matches whole path segments: "/api" matches
+// "/api" and "/api/users" but not "/apis", while "/api/" only matches the
+// paths starting with it. Paths are cleaned first, like ServeMux does, so
+// "/static/../api/users" and "//api" are below "/api".
+func PathPrefix(prefix string) Predicate {
+	return func(r *http.Request) bool {
+		p := cleanPath(r.URL.Path)
+		if strings.HasSuffix(prefix, "/") {
+			return strings.HasPrefix(p, prefix)
+		}
+		return p == prefix || strings.HasPrefix(p, prefix+"/")
+	}
+}
+
+// cleanPath returns the canonical form of p, keeping a trailing slash.
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	if p[0] != '/' {
+		p = "/" + p
+	}
+	np := path.Clean(p)
+	if p[len(p)-1] == '/' && np != "/" {
+		np += "/"
+	}
+	return np
+}
+
+// Methods matches the requests with one of the given methods, e.g. "POST".
+func Methods(methods ...string) Predicate {
+	return func(r *http.Request) bool {
+		for _, m := range methods {
+			if r.Method == m {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not matches the requests pred doesn't match.
+func Not(pred Predicate) Predicate {
+	return func(r *http.Request) bool {
+		return !pred(r)
+	}
+}
+
+// And matches the requests matching all of preds.
+func And(preds ...Predicate) Predicate {
+	return func(r *http.Request) bool {
+		for _, p := range preds {
+			if !p(r) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or matches the requests matching any of preds.
+func Or(preds ...Predicate) Predicate {
+	return func(r *http.Request) bool {
+		for _, p := range preds {
+			if p(r) {
+				return true
+			}
+		}
+		return false
+	}
+}
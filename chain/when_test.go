@@ -0,0 +1,69 @@
+package chain_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Akagi201/utils-go/chain"
+)
+
+func serve(h http.Handler, method, path string) string {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w.Body.String()
+}
+
+func TestWhen(t *testing.T) {
+	h := chain.New(
+		tagMiddleware("all\n"),
+		chain.When(chain.PathPrefix("/api"), tagMiddleware("api\n")),
+		chain.When(chain.Methods("POST", "PUT"), tagMiddleware("write\n")),
+		chain.Unless(chain.PathPrefix("/static/"), tagMiddleware("dynamic\n")),
+	).Then(testApp)
+
+	tests := []struct {
+		method, path, expected string
+	}{
+		{"GET", "/", "all\ndynamic\napp\n"},
+		{"GET", "/api", "all\napi\ndynamic\napp\n"},
+		{"POST", "/api/users", "all\napi\nwrite\ndynamic\napp\n"},
+		{"GET", "/apis", "all\ndynamic\napp\n"},
+		{"PUT", "/static/app.js", "all\nwrite\napp\n"},
+		{"GET", "/static", "all\ndynamic\napp\n"},
+		{"GET", "/static/../api/users", "all\napi\ndynamic\napp\n"},
+		{"GET", "//api", "all\napi\ndynamic\napp\n"},
+		{"PUT", "/./static/a/", "all\nwrite\napp\n"},
+	}
+	for _, test := range tests {
+		if got := serve(h, test.method, test.path); got != test.expected {
+			t.Errorf("%s %s: expected %q but got %q", test.method, test.path, test.expected, got)
+		}
+	}
+}
+
+func TestWhenChain(t *testing.T) {
+	admin := chain.New(tagMiddleware("auth\n"), tagMiddleware("audit\n"))
+	h := chain.New(chain.When(chain.PathPrefix("/admin/"), admin.Then)).Then(testApp)
+
+	if got := serve(h, "GET", "/admin/users"); got != "auth\naudit\napp\n" {
+		t.Errorf("Unexpected response %q", got)
+	}
+	if got := serve(h, "GET", "/users"); got != "app\n" {
+		t.Errorf("Unexpected response %q", got)
+	}
+}
+
+func TestPredicates(t *testing.T) {
+	r := httptest.NewRequest("DELETE", "/api/items/1", nil)
+	api, del, get := chain.PathPrefix("/api/"), chain.Methods("DELETE"), chain.Methods("GET")
+	if !chain.And(api, del)(r) || chain.And(api, get)(r) {
+		t.Error("And is wrong")
+	}
+	if !chain.Or(get, del)(r) || chain.Or(get, chain.Not(api))(r) {
+		t.Error("Or is wrong")
+	}
+	if !chain.And()(r) || chain.Or()(r) {
+		t.Error("Expected And() to match everything and Or() nothing")
+	}
+}
@@ -0,0 +1,165 @@
+package files
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// AtomicWriter writes a file atomically: the data goes to a temporary file in
+// the same directory, which replaces the target on Close. Readers see either
+// the old or the new content, and a crash leaves the old file in place.
+//
+//	w, err := files.NewAtomicWriter("config.json", 0644)
+//	if err != nil {
+//	    return err
+//	}
+//	defer w.Abort()
+//	if err := json.NewEncoder(w).Encode(cfg); err != nil {
+//	    return err
+//	}
+//	return w.Close()
+type AtomicWriter struct {
+	file *os.File
+	path string
+	err  error
+	done bool
+}
+
+// NewAtomicWriter creates the temporary file for an atomic write of path.
+// Like os.WriteFile, a new file gets the permissions perm, less the umask,
+// while an existing one keeps its permissions.
+func NewAtomicWriter(path string, perm os.FileMode) (*AtomicWriter, error) {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	f, err := createTemp(dir, "."+base+".tmp", perm)
+	if err != nil {
+		return nil, err
+	}
+	if fi, err := os.Stat(path); err == nil {
+		if err := f.Chmod(fi.Mode().Perm()); err != nil {
+			f.Close()
+			os.Remove(f.Name())
+			return nil, err
+		}
+	}
+	return &AtomicWriter{file: f, path: path}, nil
+}
+
+// createTemp is os.CreateTemp with the permissions perm, to which the umask
+// applies, instead of 0600.
+func createTemp(dir, prefix string, perm os.FileMode) (*os.File, error) {
+	for try := 0; ; try++ {
+		name := filepath.Join(dir, prefix+strconv.FormatUint(uint64(rand.Uint32()), 10))
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, perm)
+		if errors.Is(err, fs.ErrExist) && try < 10000 {
+			continue
+		}
+		return f, err
+	}
+}
+
+// Write writes to the temporary file. After a failed Write, Close discards
+// the file instead of replacing the target.
+func (w *AtomicWriter) Write(p []byte) (int, error) {
+	if w.done {
+		return 0, os.ErrClosed
+	}
+	n, err := w.file.Write(p)
+	if err != nil && w.err == nil {
+		w.err = err
+	}
+	return n, err
+}
+
+// Close flushes the temporary file to disk, renames it over the target and
+// syncs the directory so the rename survives a crash.
+func (w *AtomicWriter) Close() error {
+	if w.done {
+		return os.ErrClosed
+	}
+	if w.err != nil {
+		w.Abort()
+		return fmt.Errorf("files: atomic write of %s aborted: %w", w.path, w.err)
+	}
+	w.done = true
+
+	tmp := w.file.Name()
+	if err := w.file.Sync(); err != nil {
+		w.file.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := w.file.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, w.path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return syncDir(filepath.Dir(w.path))
+}
+
+// Abort discards the temporary file, leaving the target alone. It does
+// nothing after Close, so it can be deferred.
+func (w *AtomicWriter) Abort() error {
+	if w.done {
+		return nil
+	}
+	w.done = true
+	err := w.file.Close()
+	if rmErr := os.Remove(w.file.Name()); rmErr != nil && !errors.Is(rmErr, os.ErrNotExist) {
+		return rmErr
+	}
+	return err
+}
+
+// WriteAtomic writes data to path atomically, see AtomicWriter.
+func WriteAtomic(path string, data []byte, perm os.FileMode) error {
+	w, err := NewAtomicWriter(path, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		w.Abort()
+		return err
+	}
+	return w.Close()
+}
+
+// WriteLinesSliceAtomic is like WriteLinesSlice but replaces the file
+// atomically, see AtomicWriter. An existing file keeps its permissions, a new
+// one gets 0644.
+func WriteLinesSliceAtomic(lines []string, path string) error {
+	w, err := NewAtomicWriter(path, 0o644)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	for _, line := range lines {
+		fmt.Fprintln(bw, line)
+	}
+	if err := bw.Flush(); err != nil {
+		w.Abort()
+		return err
+	}
+	return w.Close()
+}
+
+// syncDir fsyncs a directory, making the entries created or renamed in it durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
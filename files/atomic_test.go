@@ -0,0 +1,116 @@
+package files_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Akagi201/utils-go/files"
+)
+
+func TestWriteAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data.txt")
+
+	if err := files.WriteAtomic(path, []byte("one"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := files.WriteAtomic(path, []byte("two"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "two" {
+		t.Fatalf("Expected two but got %q (%v)", data, err)
+	}
+	if fi, _ := os.Stat(path); fi.Mode().Perm() != 0o600 {
+		t.Errorf("Unexpected mode %s", fi.Mode())
+	}
+	assertOnlyFiles(t, dir, "data.txt")
+}
+
+func TestWriteAtomicPermissions(t *testing.T) {
+	dir := t.TempDir()
+	path, plain := filepath.Join(dir, "data.txt"), filepath.Join(dir, "plain.txt")
+
+	// like os.WriteFile, which the umask applies to
+	if err := files.WriteAtomic(path, []byte("one"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(plain, []byte("one"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	fi, _ := os.Stat(path)
+	pi, _ := os.Stat(plain)
+	if fi.Mode() != pi.Mode() {
+		t.Errorf("Expected mode %s like os.WriteFile but got %s", pi.Mode(), fi.Mode())
+	}
+
+	// an existing file keeps its mode
+	if err := os.Chmod(path, 0o640); err != nil {
+		t.Fatal(err)
+	}
+	if err := files.WriteAtomic(path, []byte("two"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if fi, _ := os.Stat(path); fi.Mode().Perm() != 0o640 {
+		t.Errorf("Expected the mode to be kept but got %s", fi.Mode())
+	}
+}
+
+func TestAtomicWriterAbort(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data.txt")
+	if err := os.WriteFile(path, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := files.NewAtomicWriter(path, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Abort(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Expected os.ErrClosed but got %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "old" {
+		t.Errorf("Expected the old content but got %q", data)
+	}
+	assertOnlyFiles(t, dir, "data.txt")
+}
+
+func TestWriteLinesSliceAtomic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lines.txt")
+	if err := files.WriteLinesSliceAtomic([]string{"a", "b"}, path); err != nil {
+		t.Fatal(err)
+	}
+	lines, err := files.ReadLinesSlice(path)
+	if err != nil || len(lines) != 2 || lines[1] != "b" {
+		t.Errorf("Unexpected lines %q (%v)", lines, err)
+	}
+}
+
+func assertOnlyFiles(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Name())
+	}
+	if len(got) != len(names) {
+		t.Fatalf("Expected %q in %s but found %q", names, dir, got)
+	}
+	for i := range got {
+		if got[i] != names[i] {
+			t.Fatalf("Expected %q in %s but found %q", names, dir, got)
+		}
+	}
+}
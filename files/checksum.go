@@ -0,0 +1,274 @@
+package files
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Algorithm is a hash algorithm of Checksum, the same as in the hashs package.
+type Algorithm string
+
+// Supported algorithms
+const (
+	MD5    Algorithm = "md5"
+	SHA1   Algorithm = "sha1"
+	SHA256 Algorithm = "sha256"
+	SHA512 Algorithm = "sha512"
+	FNV32  Algorithm = "fnv32"
+	FNV32a Algorithm = "fnv32a"
+	FNV64  Algorithm = "fnv64"
+	FNV64a Algorithm = "fnv64a"
+)
+
+func (algo Algorithm) new() (hash.Hash, error) {
+	switch algo {
+	case MD5:
+		return md5.New(), nil
+	case SHA1:
+		return sha1.New(), nil
+	case SHA256:
+		return sha256.New(), nil
+	case SHA512:
+		return sha512.New(), nil
+	case FNV32:
+		return fnv.New32(), nil
+	case FNV32a:
+		return fnv.New32a(), nil
+	case FNV64:
+		return fnv.New64(), nil
+	case FNV64a:
+		return fnv.New64a(), nil
+	}
+	return nil, fmt.Errorf("files: unknown hash algorithm %q", string(algo))
+}
+
+// Checksum returns the hex encoded hash of a file's content, reading it as a
+// stream. FNV sums are encoded big-endian, so FNV32 gives
+// fmt.Sprintf("%08x", hashs.FNV32(content)).
+func Checksum(path string, algo Algorithm) (string, error) {
+	h, err := algo.new()
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ManifestEntry describes a file of a Manifest.
+type ManifestEntry struct {
+	// Path is slash separated and relative to the manifest's directory.
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	Hash    string    `json:"hash"`
+}
+
+// Manifest lists the regular files below a directory with their hashes,
+// sorted by path. It is saved as JSON.
+type Manifest struct {
+	Algorithm Algorithm       `json:"algorithm"`
+	Entries   []ManifestEntry `json:"entries"`
+}
+
+// BuildManifest hashes the regular files below dir, several at a time.
+// Symlinks aren't followed.
+//
+//	m, err := files.BuildManifest("dist", files.SHA256)
+//	if err != nil {
+//	    return err
+//	}
+//	diff := previous.Diff(m)
+//	for _, e := range append(diff.Added, diff.Modified...) {
+//	    upload(e.Path)
+//	}
+func BuildManifest(dir string, algo Algorithm) (*Manifest, error) {
+	if _, err := algo.new(); err != nil {
+		return nil, err
+	}
+	m := &Manifest{Algorithm: algo, Entries: []ManifestEntry{}}
+	var mu sync.Mutex
+	err := Walk(dir, func(p string, info fs.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		sum, err := Checksum(p, algo)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		m.Entries = append(m.Entries, ManifestEntry{
+			Path:    filepath.ToSlash(rel),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+			Hash:    sum,
+		})
+		mu.Unlock()
+		return nil
+	}, &WalkOptions{Parallel: 8})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(m.Entries, func(i, j int) bool { return m.Entries[i].Path < m.Entries[j].Path })
+	return m, nil
+}
+
+// LoadManifest reads a manifest saved by Save.
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := &Manifest{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("files: %s: %w", path, err)
+	}
+	return m, nil
+}
+
+// Save writes the manifest as JSON, atomically.
+func (m *Manifest) Save(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return WriteAtomic(path, append(data, '\n'), 0o644)
+}
+
+// ManifestDiff is the difference between two manifests.
+type ManifestDiff struct {
+	Added    []ManifestEntry
+	Removed  []ManifestEntry
+	Modified []ManifestEntry
+}
+
+// Empty reports whether the manifests list the same files.
+func (d ManifestDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0
+}
+
+// Diff compares the manifest with a newer one. Modified has the entries of
+// newer whose content changed, compared by hash, or by size and modification
+// time if the manifests use different algorithms. All lists are sorted by
+// path.
+func (m *Manifest) Diff(newer *Manifest) ManifestDiff {
+	old := make(map[string]ManifestEntry, len(m.Entries))
+	for _, e := range m.Entries {
+		old[e.Path] = e
+	}
+	sameAlgo := m.Algorithm == newer.Algorithm
+
+	var d ManifestDiff
+	for _, e := range newer.Entries {
+		o, ok := old[e.Path]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, e)
+		case o.Size != e.Size,
+			sameAlgo && o.Hash != e.Hash,
+			!sameAlgo && !o.ModTime.Equal(e.ModTime):
+			d.Modified = append(d.Modified, e)
+		}
+		delete(old, e.Path)
+	}
+	for _, e := range m.Entries {
+		if _, ok := old[e.Path]; ok {
+			d.Removed = append(d.Removed, e)
+		}
+	}
+	for _, l := range [][]ManifestEntry{d.Added, d.Removed, d.Modified} {
+		sort.Slice(l, func(i, j int) bool { return l[i].Path < l[j].Path })
+	}
+	return d
+}
+
+// Duplicates returns the groups of paths of the files with the same content,
+// each sorted, ordered by their first path.
+func (m *Manifest) Duplicates() [][]string {
+	type key struct {
+		size int64
+		hash string
+	}
+	groups := map[key][]string{}
+	for _, e := range m.Entries {
+		k := key{e.Size, e.Hash}
+		groups[k] = append(groups[k], e.Path)
+	}
+	return sortGroups(groups)
+}
+
+// FindDuplicates returns the groups of paths of the files with the same
+// content below dir, like Manifest.Duplicates, but only hashes the files
+// with the same size as another one.
+func FindDuplicates(dir string, algo Algorithm) ([][]string, error) {
+	if _, err := algo.new(); err != nil {
+		return nil, err
+	}
+	bySize := map[int64][]string{}
+	var mu sync.Mutex
+	err := Walk(dir, func(p string, info fs.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		mu.Lock()
+		bySize[info.Size()] = append(bySize[info.Size()], p)
+		mu.Unlock()
+		return nil
+	}, &WalkOptions{Parallel: 8})
+	if err != nil {
+		return nil, err
+	}
+
+	byHash := map[string][]string{}
+	for size, paths := range bySize {
+		if len(paths) < 2 {
+			continue
+		}
+		for _, p := range paths {
+			sum, err := Checksum(p, algo)
+			if err != nil {
+				return nil, err
+			}
+			k := fmt.Sprintf("%d:%s", size, sum)
+			byHash[k] = append(byHash[k], p)
+		}
+	}
+	return sortGroups(byHash), nil
+}
+
+// sortGroups returns the groups of more than one path, sorted.
+func sortGroups[K comparable](groups map[K][]string) [][]string {
+	var dups [][]string
+	for _, paths := range groups {
+		if len(paths) > 1 {
+			sort.Strings(paths)
+			dups = append(dups, paths)
+		}
+	}
+	sort.Slice(dups, func(i, j int) bool { return dups[i][0] < dups[j][0] })
+	return dups
+}
@@ -0,0 +1,139 @@
+package files_test
+
+import (
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Akagi201/utils-go/files"
+)
+
+func TestChecksum(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data")
+	if err := os.WriteFile(path, []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte("hello"))
+	tests := map[files.Algorithm]string{
+		files.MD5:    "5d41402abc4b2a76b9719d911017c592",
+		files.SHA1:   "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d",
+		files.SHA256: "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+		files.FNV32a: fmt.Sprintf("%08x", h.Sum32()),
+	}
+	for algo, expected := range tests {
+		sum, err := files.Checksum(path, algo)
+		if err != nil || sum != expected {
+			t.Errorf("%s: expected %s but got %s (%v)", algo, expected, sum, err)
+		}
+	}
+
+	if _, err := files.Checksum(path, "crc"); err == nil {
+		t.Error("Expected an error for an unknown algorithm")
+	}
+}
+
+func TestManifest(t *testing.T) {
+	dir := t.TempDir()
+	makeTree(t, dir, map[string]string{
+		"a":     "same",
+		"b/c":   "same",
+		"b/d":   "other",
+		"b/e/f": "gone",
+	})
+
+	old, err := files.BuildManifest(dir, files.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, e := range old.Entries {
+		paths = append(paths, e.Path)
+	}
+	if expected := []string{"a", "b/c", "b/d", "b/e/f"}; !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Expected %v but got %v", expected, paths)
+	}
+	if e := old.Entries[2]; e.Size != 5 || e.Hash == "" || e.ModTime.IsZero() {
+		t.Errorf("Unexpected entry %+v", e)
+	}
+	if dups := old.Duplicates(); !reflect.DeepEqual(dups, [][]string{{"a", "b/c"}}) {
+		t.Errorf("Unexpected duplicates %v", dups)
+	}
+
+	saved := filepath.Join(t.TempDir(), "manifest.json")
+	if err := old.Save(saved); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := files.LoadManifest(saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := loaded.Diff(old); !d.Empty() {
+		t.Errorf("Expected no difference with the saved manifest but got %+v", d)
+	}
+
+	makeTree(t, dir, map[string]string{"b/d": "changed", "g": "new"})
+	if err := os.Remove(filepath.Join(dir, "b", "e", "f")); err != nil {
+		t.Fatal(err)
+	}
+	// same size and time, different content
+	past := time.Now().Add(-time.Hour)
+	if err := os.WriteFile(filepath.Join(dir, "a"), []byte("diff"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(filepath.Join(dir, "a"), past, past)
+
+	cur, err := files.BuildManifest(dir, files.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := loaded.Diff(cur)
+	names := func(entries []files.ManifestEntry) []string {
+		var l []string
+		for _, e := range entries {
+			l = append(l, e.Path)
+		}
+		return l
+	}
+	if got := names(d.Added); !reflect.DeepEqual(got, []string{"g"}) {
+		t.Errorf("Unexpected added %v", got)
+	}
+	if got := names(d.Removed); !reflect.DeepEqual(got, []string{"b/e/f"}) {
+		t.Errorf("Unexpected removed %v", got)
+	}
+	if got := names(d.Modified); !reflect.DeepEqual(got, []string{"a", "b/d"}) {
+		t.Errorf("Unexpected modified %v", got)
+	}
+}
+
+func TestFindDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	makeTree(t, dir, map[string]string{
+		"x/1": "abc",
+		"y/2": "abc",
+		"z/3": "abc",
+		"4":   "abd",
+		"5":   "long content",
+		"6":   "long content",
+		"7":   "unique",
+	})
+	dups, err := files.FindDuplicates(dir, files.SHA1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	join := func(names ...string) []string {
+		for i, n := range names {
+			names[i] = filepath.Join(dir, n)
+		}
+		return names
+	}
+	expected := [][]string{join("5", "6"), join("x/1", "y/2", "z/3")}
+	if !reflect.DeepEqual(dups, expected) {
+		t.Errorf("Expected %v but got %v", expected, dups)
+	}
+}
@@ -0,0 +1,486 @@
+package files
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrSymlinkLoop is passed to a WalkFunc for a symlink pointing to one of
+// its parent directories. Returning nil skips it.
+var ErrSymlinkLoop = errors.New("files: symlink loop")
+
+// CopyFile copies a regular file, keeping its permissions and modification
+// time. dst is replaced atomically, see AtomicWriter.
+func CopyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return &os.PathError{Op: "copy", Path: src, Err: errors.New("not a regular file")}
+	}
+
+	w, err := NewAtomicWriter(dst, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	defer w.Abort()
+	if _, err := io.Copy(w, in); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	// without the umask, and even if dst existed
+	if err := os.Chmod(dst, info.Mode().Perm()); err != nil {
+		return err
+	}
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
+
+// CopyDirOptions filters the files copied by CopyDir. Patterns are matched
+// with Match against the slash separated path relative to the source.
+type CopyDirOptions struct {
+	// Include, if not empty, restricts the copy to the files matching one of
+	// the patterns, e.g. "**/*.go". Directories are only created as needed.
+	Include []string
+	// Exclude skips the files and directories matching one of the patterns,
+	// e.g. "**/node_modules".
+	Exclude []string
+}
+
+// CopyDir copies the directory tree src to dst, which is created if needed,
+// keeping permissions, modification times of files and symlinks as they
+// are. opts may be nil.
+//
+//	err := files.CopyDir("site", "public", &files.CopyDirOptions{
+//	    Exclude: []string{"**/.git", "**/*.tmp"},
+//	})
+func CopyDir(src, dst string, opts *CopyDirOptions) error {
+	if opts == nil {
+		opts = &CopyDirOptions{}
+	}
+	for _, p := range append(append([]string(nil), opts.Include...), opts.Exclude...) {
+		if _, err := Match(p, ""); err != nil {
+			return err
+		}
+	}
+
+	// created holds the permissions of the directories created below dst,
+	// which are writable by the user until everything is copied
+	created := map[string]fs.FileMode{}
+	// ensureDir creates the directory rel below dst like the one below src.
+	var ensureDir func(rel string) error
+	ensureDir = func(rel string) error {
+		if _, ok := created[rel]; ok {
+			return nil
+		}
+		if rel != "." {
+			if err := ensureDir(filepath.Dir(rel)); err != nil {
+				return err
+			}
+		}
+		info, err := os.Stat(filepath.Join(src, rel))
+		if err != nil {
+			return err
+		}
+		if err := os.Mkdir(filepath.Join(dst, rel), info.Mode().Perm()|0o700); err != nil && !errors.Is(err, fs.ErrExist) {
+			return err
+		}
+		created[rel] = info.Mode().Perm()
+		return nil
+	}
+
+	err := Walk(src, func(p string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		slashRel := filepath.ToSlash(rel)
+		if rel != "." && matchAny(opts.Exclude, slashRel) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		switch {
+		case info.IsDir():
+			if len(opts.Include) == 0 {
+				return ensureDir(rel)
+			}
+			return nil
+		case len(opts.Include) > 0 && !matchAny(opts.Include, slashRel):
+			return nil
+		}
+		if err := ensureDir(filepath.Dir(rel)); err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if info.Mode()&fs.ModeSymlink != 0 {
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			os.Remove(target)
+			return os.Symlink(link, target)
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		return CopyFile(p, target)
+	}, nil)
+	if err != nil {
+		return err
+	}
+
+	// children first, as a read-only parent would prevent their chmod
+	dirs := make([]string, 0, len(created))
+	for rel := range created {
+		dirs = append(dirs, rel)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+	for _, rel := range dirs {
+		if err := os.Chmod(filepath.Join(dst, rel), created[rel]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Match reports whether a slash separated path matches a pattern. It
+// extends path.Match with "**", which as a whole path segment matches any
+// number of segments, including none:
+//
+//	files.Match("src/**/*.go", "src/a/b/c.go") // true
+//	files.Match("**/*_test.go", "x_test.go")  // true
+//
+// The only possible error is path.ErrBadPattern.
+func Match(pattern, name string) (bool, error) {
+	pats := strings.Split(pattern, "/")
+	for _, p := range pats {
+		if p != "**" {
+			if _, err := path.Match(p, ""); err != nil {
+				return false, err
+			}
+		}
+	}
+	var parts []string
+	if name != "" {
+		parts = strings.Split(name, "/")
+	}
+	return matchSegments(pats, parts), nil
+}
+
+func matchSegments(pats, parts []string) bool {
+	for len(pats) > 0 {
+		if pats[0] == "**" {
+			// collapse consecutive ** and try every split point
+			for len(pats) > 0 && pats[0] == "**" {
+				pats = pats[1:]
+			}
+			if len(pats) == 0 {
+				return true
+			}
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(pats, parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pats[0], parts[0]); !ok {
+			return false
+		}
+		pats, parts = pats[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
+
+// Glob is like filepath.Glob with the "**" of Match, and returns the
+// matching files and directories in lexical order. Symlinks aren't followed.
+//
+//	sources, err := files.Glob("cmd/**/*.go")
+//
+// Only the directories the pattern can reach are read: without "**", the
+// walk stops at the depth of the pattern.
+func Glob(pattern string) ([]string, error) {
+	return glob(pattern, nil)
+}
+
+// glob implements Glob, calling visited for each directory it reads.
+func glob(pattern string, visited func(dir string)) ([]string, error) {
+	slashPattern := filepath.ToSlash(pattern)
+	if _, err := Match(slashPattern, ""); err != nil {
+		return nil, err
+	}
+
+	// walk from the longest directory without wildcards
+	segs := strings.Split(slashPattern, "/")
+	i := 0
+	for i < len(segs)-1 && !strings.ContainsAny(segs[i], `*?[\`) {
+		i++
+	}
+	base := strings.Join(segs[:i], "/")
+	if i == len(segs)-1 && !strings.ContainsAny(segs[i], `*?[\`) {
+		if _, err := os.Lstat(pattern); err != nil {
+			return nil, nil
+		}
+		return []string{pattern}, nil
+	}
+	root := filepath.FromSlash(base)
+	if base == "" {
+		root = "."
+		if strings.HasPrefix(slashPattern, "/") {
+			root = "/"
+		}
+	}
+	rest := strings.Join(segs[i:], "/")
+	// the depth below root the pattern reaches, unlimited with **
+	maxDepth := len(segs) - i
+	for _, seg := range segs[i:] {
+		if seg == "**" {
+			maxDepth = -1
+		}
+	}
+
+	var matches []string
+	err := Walk(root, func(p string, info fs.FileInfo, err error) error {
+		if err != nil {
+			// unreadable directories are skipped, like filepath.Glob does
+			if info != nil && info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return nil
+		}
+		if rel != "." {
+			slashRel := filepath.ToSlash(rel)
+			if ok, _ := Match(rest, slashRel); ok {
+				matches = append(matches, p)
+			}
+			if info.IsDir() && maxDepth >= 0 && strings.Count(slashRel, "/")+1 >= maxDepth {
+				return filepath.SkipDir
+			}
+		}
+		if info.IsDir() && visited != nil {
+			visited(p)
+		}
+		return nil
+	}, nil)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	// the walk puts a/b/c before a/b.txt
+	sort.Strings(matches)
+	return matches, err
+}
+
+// DirSize returns the total size of the regular files below a directory.
+// Symlinks aren't followed.
+func DirSize(dir string) (int64, error) {
+	var size int64
+	var mu sync.Mutex
+	err := Walk(dir, func(p string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			mu.Lock()
+			size += info.Size()
+			mu.Unlock()
+		}
+		return nil
+	}, &WalkOptions{Parallel: 8})
+	return size, err
+}
+
+// WalkFunc is called by Walk for each file and directory, with the error
+// met if the directory couldn't be read (then called a second time for it),
+// or if it is ErrSymlinkLoop. Returning filepath.SkipDir for a directory
+// skips its content; any other error stops the walk.
+type WalkFunc func(path string, info fs.FileInfo, err error) error
+
+// WalkOptions configures Walk.
+type WalkOptions struct {
+	// Parallel is the number of directories read at the same time. With more
+	// than one, fn is called concurrently and in no particular order.
+	Parallel int
+	// FollowSymlinks walks the directories symlinks point to, as if they
+	// were regular directories, and passes the FileInfo of their targets.
+	// Symlinks to one of their parents get ErrSymlinkLoop.
+	FollowSymlinks bool
+}
+
+// Walk walks the tree rooted at root like filepath.Walk, calling fn for
+// root and everything below it, depth first with the entries of each
+// directory in lexical order, unless walking in parallel.
+// opts may be nil.
+//
+//	err := files.Walk("/srv/data", func(path string, info fs.FileInfo, err error) error {
+//	    if err != nil {
+//	        return err
+//	    }
+//	    fmt.Println(path, info.Size())
+//	    return nil
+//	}, &files.WalkOptions{Parallel: 8, FollowSymlinks: true})
+func Walk(root string, fn WalkFunc, opts *WalkOptions) error {
+	w := &walker{fn: fn}
+	if opts != nil {
+		w.opts = *opts
+	}
+	if w.opts.Parallel > 1 {
+		w.sem = make(chan struct{}, w.opts.Parallel-1)
+	}
+
+	stat := os.Lstat
+	if w.opts.FollowSymlinks {
+		stat = os.Stat
+	}
+	info, err := stat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = w.visit(root, info, nil)
+	}
+	if err == filepath.SkipDir {
+		err = nil
+	}
+	w.wg.Wait()
+	if err != nil {
+		return err
+	}
+	return w.err
+}
+
+type walker struct {
+	fn   WalkFunc
+	opts WalkOptions
+	sem  chan struct{}
+	wg   sync.WaitGroup
+
+	mu  sync.Mutex
+	err error
+}
+
+func (w *walker) failed() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err != nil
+}
+
+func (w *walker) fail(err error) {
+	w.mu.Lock()
+	if w.err == nil {
+		w.err = err
+	}
+	w.mu.Unlock()
+}
+
+// visit calls fn for a file or directory and walks the directory's content.
+// ancestors holds the directories above it, to detect symlink loops.
+func (w *walker) visit(p string, info fs.FileInfo, ancestors []fs.FileInfo) error {
+	if w.failed() {
+		return nil
+	}
+	if info.IsDir() {
+		for _, a := range ancestors {
+			if os.SameFile(a, info) {
+				return w.fn(p, info, ErrSymlinkLoop)
+			}
+		}
+	}
+	if err := w.fn(p, info, nil); err != nil || !info.IsDir() {
+		return err
+	}
+
+	entries, err := readDirNames(p)
+	if err != nil {
+		if err := w.fn(p, info, err); err != nil && err != filepath.SkipDir {
+			return err
+		}
+		return nil
+	}
+	ancestors = append(ancestors[:len(ancestors):len(ancestors)], info)
+
+	for _, name := range entries {
+		child := filepath.Join(p, name)
+		ci, err := os.Lstat(child)
+		if err == nil && w.opts.FollowSymlinks && ci.Mode()&fs.ModeSymlink != 0 {
+			if ti, serr := os.Stat(child); serr == nil {
+				ci = ti
+			}
+		}
+		if err != nil {
+			if err := w.fn(child, nil, err); err != nil && err != filepath.SkipDir {
+				return err
+			}
+			continue
+		}
+
+		if ci.IsDir() && w.sem != nil {
+			select {
+			case w.sem <- struct{}{}:
+				w.wg.Add(1)
+				go func(child string, ci fs.FileInfo) {
+					defer w.wg.Done()
+					defer func() { <-w.sem }()
+					if err := w.visit(child, ci, ancestors); err != nil && err != filepath.SkipDir {
+						w.fail(err)
+					}
+				}(child, ci)
+				continue
+			default:
+				// all workers are busy: walk it here
+			}
+		}
+		if err := w.visit(child, ci, ancestors); err != nil && err != filepath.SkipDir {
+			return err
+		}
+	}
+	return nil
+}
+
+func readDirNames(dir string) ([]string, error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	names, err := f.Readdirnames(-1)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,51 @@
+package files
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGlobDepth(t *testing.T) {
+	dir := t.TempDir()
+	for _, d := range []string{"a/deep/er", ".git/objects/ab", "node_modules/x"} {
+		if err := os.MkdirAll(filepath.Join(dir, d), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, f := range []string{"main.go", "a/main.go"} {
+		if err := os.WriteFile(filepath.Join(dir, f), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		pattern string
+		matches []string
+		visited []string
+	}{
+		{"*.go", []string{"main.go"}, []string{"."}},
+		{"*/*.go", []string{"a/main.go"}, []string{".", ".git", "a", "node_modules"}},
+	}
+	for _, test := range tests {
+		var visited []string
+		matches, err := glob(filepath.Join(dir, test.pattern), func(d string) {
+			rel, _ := filepath.Rel(dir, d)
+			visited = append(visited, filepath.ToSlash(rel))
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, m := range test.matches {
+			test.matches[i] = filepath.Join(dir, m)
+		}
+		if !reflect.DeepEqual(matches, test.matches) {
+			t.Errorf("%s: expected %v but got %v", test.pattern, test.matches, matches)
+		}
+		// the directories deeper than the pattern aren't read
+		if !reflect.DeepEqual(visited, test.visited) {
+			t.Errorf("%s: expected only %v to be read but got %v", test.pattern, test.visited, visited)
+		}
+	}
+}
@@ -0,0 +1,383 @@
+package files_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Akagi201/utils-go/files"
+)
+
+// makeTree creates the files of a map of slash separated paths to contents
+// below dir.
+func makeTree(t *testing.T, dir string, tree map[string]string) {
+	t.Helper()
+	for name, content := range tree {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// listTree returns the slash separated paths of the files below dir.
+func listTree(t *testing.T, dir string) []string {
+	t.Helper()
+	var names []string
+	err := filepath.Walk(dir, func(p string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			rel, _ := filepath.Rel(dir, p)
+			names = append(names, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return names
+}
+
+func TestCopyFile(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.sh")
+	if err := os.WriteFile(src, []byte("#!/bin/sh\n"), 0o750); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(src, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := filepath.Join(dir, "dst.sh")
+	if err := files.CopyFile(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o750 {
+		t.Errorf("Unexpected mode %s", info.Mode())
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Errorf("Expected mtime %s but got %s", mtime, info.ModTime())
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "#!/bin/sh\n" {
+		t.Errorf("Unexpected content %q", data)
+	}
+
+	if err := files.CopyFile(dir, dst); err == nil {
+		t.Error("Expected an error copying a directory")
+	}
+}
+
+func TestCopyDir(t *testing.T) {
+	src := t.TempDir()
+	makeTree(t, src, map[string]string{
+		"a.go":                  "a",
+		"a.txt":                 "a",
+		"sub/b.go":              "b",
+		"sub/b.tmp":             "b",
+		"sub/deep/c.go":         "c",
+		"node_modules/x/x.go":   "x",
+		"docs/readme.md":        "r",
+		"sub/node_modules/y.go": "y",
+	})
+	if err := os.Symlink("a.go", filepath.Join(src, "link.go")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(src, "empty"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := filepath.Join(t.TempDir(), "copy")
+	err := files.CopyDir(src, dst, &files.CopyDirOptions{
+		Exclude: []string{"**/node_modules", "**/*.tmp"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"a.go", "a.txt", "docs/readme.md", "link.go", "sub/b.go", "sub/deep/c.go"}
+	if got := listTree(t, dst); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v but got %v", expected, got)
+	}
+	if link, err := os.Readlink(filepath.Join(dst, "link.go")); err != nil || link != "a.go" {
+		t.Errorf("Expected a symlink to a.go but got %q (%v)", link, err)
+	}
+	if info, err := os.Stat(filepath.Join(dst, "empty")); err != nil || info.Mode().Perm() != 0o700 {
+		t.Errorf("Expected the empty directory with mode 0700 (%v)", err)
+	}
+
+	dst = filepath.Join(t.TempDir(), "copy")
+	err = files.CopyDir(src, dst, &files.CopyDirOptions{
+		Include: []string{"**/*.go"},
+		Exclude: []string{"**/node_modules"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{"a.go", "link.go", "sub/b.go", "sub/deep/c.go"}
+	if got := listTree(t, dst); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v but got %v", expected, got)
+	}
+	for _, name := range []string{"docs", "empty"} {
+		if ok, _ := files.Exists(filepath.Join(dst, name)); ok {
+			t.Errorf("Expected no %s directory without included files", name)
+		}
+	}
+
+	if err := files.CopyDir(src, dst, &files.CopyDirOptions{Include: []string{"["}}); err == nil {
+		t.Error("Expected an error for a bad pattern")
+	}
+}
+
+func TestCopyDirReadOnly(t *testing.T) {
+	src := t.TempDir()
+	makeTree(t, src, map[string]string{"ro/a.txt": "a", "ro/sub/b.txt": "b"})
+	for _, dir := range []string{"ro/sub", "ro"} {
+		if err := os.Chmod(filepath.Join(src, dir), 0o555); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dst := filepath.Join(t.TempDir(), "copy")
+	// let the temporary directories be removed
+	t.Cleanup(func() {
+		for _, dir := range []string{src, dst} {
+			os.Chmod(filepath.Join(dir, "ro"), 0o755)
+			os.Chmod(filepath.Join(dir, "ro", "sub"), 0o755)
+		}
+	})
+
+	if err := files.CopyDir(src, dst, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, expected := listTree(t, dst), []string{"ro/a.txt", "ro/sub/b.txt"}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v but got %v", expected, got)
+	}
+	for _, dir := range []string{"ro", "ro/sub"} {
+		if info, err := os.Stat(filepath.Join(dst, dir)); err != nil || info.Mode().Perm() != 0o555 {
+			t.Errorf("Expected %s with mode 0555 (%v)", dir, err)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		match         bool
+	}{
+		{"*.go", "a.go", true},
+		{"*.go", "sub/a.go", false},
+		{"**/*.go", "a.go", true},
+		{"**/*.go", "sub/deep/a.go", true},
+		{"src/**/*.go", "src/a.go", true},
+		{"src/**/*.go", "src/a/b/c.go", true},
+		{"src/**/*.go", "lib/a.go", false},
+		{"src/**", "src/a/b", true},
+		{"src/**", "src", true},
+		{"**/node_modules", "a/node_modules", true},
+		{"**/node_modules", "a/node_modules/b", false},
+		{"a/**/**/b", "a/b", true},
+		{"a/?/c", "a/b/c", true},
+		{"a/[bc]/d", "a/x/d", false},
+	}
+	for _, test := range tests {
+		match, err := files.Match(test.pattern, test.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if match != test.match {
+			t.Errorf("Match(%q, %q) = %v", test.pattern, test.name, match)
+		}
+	}
+	if _, err := files.Match("a/[", "a/b"); err == nil {
+		t.Error("Expected an error for a bad pattern")
+	}
+}
+
+func TestGlob(t *testing.T) {
+	dir := t.TempDir()
+	makeTree(t, dir, map[string]string{
+		"cmd/a/main.go":      "",
+		"cmd/a/main_test.go": "",
+		"cmd/b/c/main.go":    "",
+		"cmd/readme.md":      "",
+		"lib.go":             "",
+	})
+
+	matches, err := files.Glob(filepath.Join(dir, "cmd", "**", "main.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		filepath.Join(dir, "cmd", "a", "main.go"),
+		filepath.Join(dir, "cmd", "b", "c", "main.go"),
+	}
+	if !reflect.DeepEqual(matches, expected) {
+		t.Errorf("Expected %v but got %v", expected, matches)
+	}
+
+	matches, err = files.Glob(filepath.Join(dir, "*.go"))
+	if err != nil || !reflect.DeepEqual(matches, []string{filepath.Join(dir, "lib.go")}) {
+		t.Errorf("Unexpected matches %v (%v)", matches, err)
+	}
+	matches, err = files.Glob(filepath.Join(dir, "lib.go"))
+	if err != nil || len(matches) != 1 {
+		t.Errorf("Unexpected matches %v (%v)", matches, err)
+	}
+	matches, err = files.Glob(filepath.Join(dir, "missing", "**"))
+	if err != nil || len(matches) != 0 {
+		t.Errorf("Unexpected matches %v (%v)", matches, err)
+	}
+
+	// lexical order, not the walk's
+	makeTree(t, dir, map[string]string{"a/b/c": "", "a/b.txt": ""})
+	matches, err = files.Glob(filepath.Join(dir, "a", "**"))
+	expected = []string{filepath.Join(dir, "a", "b"), filepath.Join(dir, "a", "b.txt"), filepath.Join(dir, "a", "b", "c")}
+	if err != nil || !reflect.DeepEqual(matches, expected) {
+		t.Errorf("Expected %v but got %v (%v)", expected, matches, err)
+	}
+}
+
+func TestDirSize(t *testing.T) {
+	dir := t.TempDir()
+	makeTree(t, dir, map[string]string{
+		"a":     "12345",
+		"b/c":   "123",
+		"b/d/e": "1",
+	})
+	size, err := files.DirSize(dir)
+	if err != nil || size != 9 {
+		t.Errorf("Expected 9 but got %d (%v)", size, err)
+	}
+	if _, err := files.DirSize(filepath.Join(dir, "missing")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected fs.ErrNotExist but got %v", err)
+	}
+}
+
+func TestWalk(t *testing.T) {
+	dir := t.TempDir()
+	makeTree(t, dir, map[string]string{
+		"a/1":   "",
+		"a/2":   "",
+		"b/c/3": "",
+		"d/4":   "",
+	})
+
+	var visited []string
+	err := files.Walk(dir, func(p string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, p)
+		visited = append(visited, filepath.ToSlash(rel))
+		if rel == "b" {
+			return filepath.SkipDir
+		}
+		return nil
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{".", "a", "a/1", "a/2", "b", "d", "d/4"}
+	if !reflect.DeepEqual(visited, expected) {
+		t.Errorf("Expected %v but got %v", expected, visited)
+	}
+
+	stop := errors.New("stop")
+	err = files.Walk(dir, func(p string, info fs.FileInfo, err error) error {
+		if filepath.Base(p) == "c" {
+			return stop
+		}
+		return err
+	}, &files.WalkOptions{Parallel: 4})
+	if err != stop {
+		t.Errorf("Expected the error of the WalkFunc but got %v", err)
+	}
+}
+
+func TestWalkParallel(t *testing.T) {
+	dir := t.TempDir()
+	tree := map[string]string{}
+	for _, d := range []string{"a", "b", "c", "d", "e"} {
+		for _, f := range []string{"1", "2", "3"} {
+			tree[d+"/"+f] = ""
+			tree[d+"/sub/"+f] = ""
+		}
+	}
+	makeTree(t, dir, tree)
+
+	var mu sync.Mutex
+	var visited []string
+	err := files.Walk(dir, func(p string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			rel, _ := filepath.Rel(dir, p)
+			mu.Lock()
+			visited = append(visited, filepath.ToSlash(rel))
+			mu.Unlock()
+		}
+		return nil
+	}, &files.WalkOptions{Parallel: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(visited)
+	if expected := listTree(t, dir); !reflect.DeepEqual(visited, expected) {
+		t.Errorf("Expected %v but got %v", expected, visited)
+	}
+}
+
+func TestWalkFollowSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	makeTree(t, dir, map[string]string{
+		"data/file": "",
+		"root/x":    "",
+	})
+	if err := os.Symlink(filepath.Join(dir, "data"), filepath.Join(dir, "root", "data")); err != nil {
+		t.Fatal(err)
+	}
+	// a loop back to root
+	if err := os.Symlink(".", filepath.Join(dir, "root", "up")); err != nil {
+		t.Fatal(err)
+	}
+
+	var visited, loops []string
+	root := filepath.Join(dir, "root")
+	err := files.Walk(root, func(p string, info fs.FileInfo, err error) error {
+		rel, _ := filepath.Rel(root, p)
+		if errors.Is(err, files.ErrSymlinkLoop) {
+			loops = append(loops, filepath.ToSlash(rel))
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		visited = append(visited, filepath.ToSlash(rel))
+		return nil
+	}, &files.WalkOptions{FollowSymlinks: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{".", "data", "data/file", "x"}
+	if !reflect.DeepEqual(visited, expected) {
+		t.Errorf("Expected %v but got %v", expected, visited)
+	}
+	if !reflect.DeepEqual(loops, []string{"up"}) {
+		t.Errorf("Expected a loop at up but got %v", loops)
+	}
+}
@@ -9,12 +9,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
-	"strings"
 )
 
-var propertySplittingRegex = regexp.MustCompile(`\s*=\s*`)
-
 // ReadLinesChannel reads a text file line by line into a channel.
 //
 //   c, err := fileutil.ReadLinesChannel(fileName)
@@ -25,21 +21,27 @@ var propertySplittingRegex = regexp.MustCompile(`\s*=\s*`)
 //      fmt.Printf("  Line: %s\n", line)
 //   }
 //
-// nil is returned (with the error) if there is an error opening the file
+// nil is returned (with the error) if there is an error opening the file.
+// Read errors end the channel early and the whole channel must be drained;
+// see ReadLinesContext for error reporting and cancellation.
 //
 func ReadLinesChannel(filePath string) (<-chan string, error) {
-	c := make(chan string)
 	file, err := os.Open(filePath)
 	if err != nil {
 		return nil, err
 	}
+	c := make(chan string)
 	go func() {
 		defer file.Close()
-		scanner := bufio.NewScanner(file)
-		for scanner.Scan() {
-			c <- scanner.Text()
+		defer close(c)
+		lr, err := NewLineReader(file, &LineReaderOptions{Raw: true})
+		if err != nil {
+			return
+		}
+		defer lr.Close()
+		for lr.Scan() {
+			c <- lr.Line().Text
 		}
-		close(c)
 	}()
 	return c, nil
 }
@@ -73,6 +75,7 @@ func ReadLinesSlice(path string) ([]string, error) {
 }
 
 // WriteLinesSlice writes the given slice of lines to the given file.
+// A crash while writing leaves a truncated file; see WriteLinesSliceAtomic.
 func WriteLinesSlice(lines []string, path string) error {
 	file, err := os.Create(path)
 	if err != nil {
@@ -99,38 +102,56 @@ func Exists(path string) (bool, error) {
 	return false, err
 }
 
-// TempFileName generates a temporary filename for use in testing or whatever
+// TempFileName generates a temporary filename for use in testing or whatever.
+// If the system's random source fails, the name is that of an empty file
+// created with os.CreateTemp, and it panics if that fails too, rather than
+// returning a predictable name.
+//
+// Deprecated: another process may create the file before the caller does;
+// use CreateTemp, which creates it.
 func TempFileName(prefix, suffix string) string {
 	randBytes := make([]byte, 16)
-	rand.Read(randBytes)
+	if _, err := rand.Read(randBytes); err != nil {
+		f, err := os.CreateTemp("", prefix+"*"+suffix)
+		if err != nil {
+			// rather than returning a predictable name
+			panic("files: TempFileName: " + err.Error())
+		}
+		f.Close()
+		return f.Name()
+	}
 	return filepath.Join(os.TempDir(), prefix+hex.EncodeToString(randBytes)+suffix)
 }
 
-// Reads name-value pairs from a properties file
-// Property file has lines in the format of "name = value" (leading and trailing spaces are ignored)
+// ReadPropertiesFile reads name-value pairs from a properties file.
+// See ParseProperties for the format, and LoadProperties to keep the order
+// of the keys or to write the file back.
 func ReadPropertiesFile(fileName string) (map[string]string, error) {
-	c, err := ReadLinesChannel(fileName)
+	p, err := LoadProperties(fileName)
 	if err != nil {
 		return nil, err
 	}
-
-	properties := make(map[string]string)
-	for line := range c {
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, "#") {
-			// Ignore this line
-		} else if len(line) == 0 {
-			// Ignore this line
-		} else {
-			parts := propertySplittingRegex.Split(line, 2)
-			properties[parts[0]] = parts[1]
-		}
-	}
-
-	return properties, nil
+	return p.Map(), nil
 }
 
-// Pwd returns the present working directory
+// Pwd returns the directory of the executable, not the working directory.
+//
+// Deprecated: use ExecutableDir, or os.Getwd for the working directory.
 func Pwd() (string, error) {
-	return filepath.Abs(filepath.Dir(os.Args[0]))
+	return ExecutableDir()
+}
+
+// ExecutableDir returns the directory of the executable of the process, with
+// symlinks resolved, wherever it was started from, e.g. through $PATH or a
+// symlink. Under go run, it is the temporary build directory.
+func ExecutableDir() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	exe, err = filepath.EvalSymlinks(exe)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Dir(exe), nil
 }
@@ -0,0 +1,45 @@
+// Package filestest provides temporary files and directories for tests,
+// removed when the test ends.
+package filestest
+
+import (
+	"os"
+	"testing"
+
+	"github.com/Akagi201/utils-go/files"
+)
+
+// CreateTemp is files.CreateTemp for tests: the file is created in
+// tb.TempDir(), failing the test on error, and removed when the test ends.
+//
+//	f := filestest.CreateTemp(t, "config-*.json")
+func CreateTemp(tb testing.TB, pattern string) *os.File {
+	tb.Helper()
+	f, remove, err := files.CreateTemp(tb.TempDir(), pattern)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() {
+		if err := remove(); err != nil {
+			tb.Error(err)
+		}
+	})
+	return f
+}
+
+// TempDir is files.TempDir for tests: the directory is created below
+// os.TempDir(), failing the test on error, and removed when the test ends.
+// Unlike tb.TempDir(), its name follows pattern.
+func TempDir(tb testing.TB, pattern string) string {
+	tb.Helper()
+	name, remove, err := files.TempDir("", pattern)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() {
+		if err := remove(); err != nil {
+			tb.Error(err)
+		}
+	})
+	return name
+}
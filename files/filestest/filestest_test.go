@@ -0,0 +1,27 @@
+package filestest_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Akagi201/utils-go/files/filestest"
+)
+
+func TestTemp(t *testing.T) {
+	var name, dir string
+	t.Run("sub", func(t *testing.T) {
+		f := filestest.CreateTemp(t, "*.json")
+		name = f.Name()
+		dir = filestest.TempDir(t, "tb-")
+		if !strings.HasPrefix(filepath.Base(dir), "tb-") {
+			t.Errorf("Unexpected name %s", dir)
+		}
+	})
+	for _, p := range []string{name, dir} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed but got %v", p, err)
+		}
+	}
+}
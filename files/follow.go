@@ -0,0 +1,281 @@
+package files
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FollowOptions configures Follow.
+type FollowOptions struct {
+	// Offset is where reading starts in the current file, e.g. the Next of
+	// the last line handled before a restart. A negative offset starts at
+	// the end of the file like tail -f, and an offset past the end, left by
+	// a file truncated in the meantime, starts at the beginning.
+	Offset int64
+	// Poll disables inotify and checks the file every PollInterval.
+	Poll bool
+	// PollInterval is how often the file is checked. It defaults to 250ms
+	// when polling, and to one second as a safety net with inotify.
+	PollInterval time.Duration
+	// MaxLineSize is the size of the longest line accepted, in bytes.
+	// It defaults to DefaultMaxLineSize.
+	MaxLineSize int
+}
+
+// Follow reads the lines of a file as they are appended, like tail -F.
+//
+// When the file is renamed or removed and a new one created at path, the
+// rest of the old file is read before following the new one from its start,
+// and when the file is truncated, reading starts over. Rotation is detected
+// by comparing the file's identity (its inode on Unix) and size. A final
+// line without a terminator is only sent once the file is rotated.
+//
+// Line numbers count from the first line sent, and Offset and Next are
+// positions in the file being read at the time, so Next can be saved to
+// resume with FollowOptions.Offset. If the file doesn't exist yet, Follow
+// waits for it. Both channels are closed when ctx is done or reading fails;
+// the error channel receives at most one error, which is nil when ctx ends
+// the follow.
+//
+//	lines, errc := files.Follow(ctx, "/var/log/app.log", &files.FollowOptions{Offset: -1})
+//	for l := range lines {
+//	    fmt.Println(l.Text)
+//	}
+//	if err := <-errc; err != nil {
+//	    return err
+//	}
+func Follow(ctx context.Context, path string, opts *FollowOptions) (<-chan Line, <-chan error) {
+	if opts == nil {
+		opts = &FollowOptions{}
+	}
+	out := make(chan Line)
+	errc := make(chan error, 1)
+	fw := &follower{
+		path:   path,
+		opts:   *opts,
+		out:    out,
+		wake:   make(chan struct{}, 1),
+		offset: opts.Offset,
+	}
+	if fw.opts.MaxLineSize <= 0 {
+		fw.opts.MaxLineSize = DefaultMaxLineSize
+	}
+	go func() {
+		defer close(errc)
+		defer close(out)
+		if err := fw.run(ctx); err != nil && ctx.Err() == nil {
+			errc <- err
+		}
+	}()
+	return out, errc
+}
+
+// follower is the state of a Follow.
+type follower struct {
+	path string
+	opts FollowOptions
+	out  chan<- Line
+	wake chan struct{}
+
+	file    *os.File
+	info    os.FileInfo
+	offset  int64 // of the next byte to read
+	pending []byte
+	number  int
+}
+
+func (fw *follower) run(ctx context.Context) error {
+	defer func() {
+		if fw.file != nil {
+			fw.file.Close()
+		}
+	}()
+
+	interval := fw.opts.PollInterval
+	if !fw.opts.Poll {
+		if n, err := fw.notify(); err == nil {
+			defer n.close()
+			if interval <= 0 {
+				interval = time.Second
+			}
+		}
+	}
+	if interval <= 0 {
+		interval = 250 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := fw.check(ctx); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-fw.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// notify watches the directory of the file, waking the follower on every
+// event concerning it.
+func (fw *follower) notify() (*notifier, error) {
+	abs, err := filepath.Abs(fw.path)
+	if err != nil {
+		return nil, err
+	}
+	n, err := newNotifier(func(path string, mask uint32) {
+		if path != abs {
+			return
+		}
+		select {
+		case fw.wake <- struct{}{}:
+		default:
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := n.add(filepath.Dir(abs)); err != nil {
+		n.close()
+		return nil, err
+	}
+	return n, nil
+}
+
+// check opens the file if needed, sends the new lines and handles rotation.
+func (fw *follower) check(ctx context.Context) error {
+	if fw.file == nil {
+		if err := fw.open(); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				// whatever gets written to a new file is new
+				fw.offset = 0
+				return nil
+			}
+			return err
+		}
+	}
+	if err := fw.read(ctx); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(fw.path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		// renamed or removed: keep the old file until a new one shows up
+		return nil
+	case err != nil:
+		return err
+	case !os.SameFile(info, fw.info):
+		// rotated: finish the old file, then start the new one
+		if err := fw.read(ctx); err != nil {
+			return err
+		}
+		if len(fw.pending) > 0 {
+			if err := fw.send(ctx, fw.pending, fw.offset-int64(len(fw.pending)), fw.offset); err != nil {
+				return err
+			}
+		}
+		fw.file.Close()
+		fw.file, fw.pending, fw.offset = nil, nil, 0
+		return fw.check(ctx)
+	case info.Size() < fw.offset:
+		// truncated
+		if _, err := fw.file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		fw.pending, fw.offset = nil, 0
+		return fw.read(ctx)
+	}
+	return nil
+}
+
+func (fw *follower) open() error {
+	f, err := os.Open(fw.path)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if fw.offset < 0 || fw.offset > info.Size() {
+		if fw.offset < 0 {
+			fw.offset = info.Size()
+		} else {
+			fw.offset = 0
+		}
+	}
+	if _, err := f.Seek(fw.offset, io.SeekStart); err != nil {
+		f.Close()
+		return err
+	}
+	fw.file, fw.info = f, info
+	return nil
+}
+
+// read sends the complete lines appended to the file since the last read.
+func (fw *follower) read(ctx context.Context) error {
+	buf := make([]byte, 32*1024)
+	for {
+		k, err := fw.file.Read(buf)
+		if k > 0 {
+			fw.pending = append(fw.pending, buf[:k]...)
+			fw.offset += int64(k)
+			if err := fw.sendLines(ctx); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (fw *follower) sendLines(ctx context.Context) error {
+	start := fw.offset - int64(len(fw.pending))
+	for {
+		i := bytes.IndexByte(fw.pending, '\n')
+		if i < 0 {
+			break
+		}
+		if err := fw.send(ctx, fw.pending[:i], start, start+int64(i)+1); err != nil {
+			return err
+		}
+		fw.pending = fw.pending[i+1:]
+		start += int64(i) + 1
+	}
+	if len(fw.pending) > fw.opts.MaxLineSize {
+		return fmt.Errorf("files: line %d: %w", fw.number+1, bufio.ErrTooLong)
+	}
+	// don't let the buffer keep growing from its front
+	fw.pending = append([]byte(nil), fw.pending...)
+	return nil
+}
+
+func (fw *follower) send(ctx context.Context, text []byte, offset, next int64) error {
+	text = bytes.TrimSuffix(text, []byte("\r"))
+	if len(text) > fw.opts.MaxLineSize {
+		return fmt.Errorf("files: line %d: %w", fw.number+1, bufio.ErrTooLong)
+	}
+	fw.number++
+	select {
+	case fw.out <- Line{Text: string(text), Number: fw.number, Offset: offset, Next: next}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
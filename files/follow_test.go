@@ -0,0 +1,118 @@
+package files_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/Akagi201/utils-go/files"
+)
+
+func appendFile(t *testing.T, path, s string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(s); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func expectLine(t *testing.T, lines <-chan files.Line, text string) files.Line {
+	t.Helper()
+	select {
+	case l, ok := <-lines:
+		if !ok {
+			t.Fatalf("Expected %q but the channel is closed", text)
+		}
+		if l.Text != text {
+			t.Fatalf("Expected %q but got %q", text, l.Text)
+		}
+		return l
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for %q", text)
+	}
+	return files.Line{}
+}
+
+func testFollow(t *testing.T, poll bool) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	appendFile(t, path, "old\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// with inotify, the changes must be noticed without the safety net polling
+	interval := time.Hour
+	if poll || runtime.GOOS != "linux" {
+		interval = 10 * time.Millisecond
+	}
+	lines, errc := files.Follow(ctx, path, &files.FollowOptions{Offset: 4, Poll: poll, PollInterval: interval})
+
+	appendFile(t, path, "one\r\ntw")
+	time.Sleep(30 * time.Millisecond)
+	appendFile(t, path, "o\n")
+	l := expectLine(t, lines, "one")
+	if l.Number != 1 || l.Offset != 4 || l.Next != 9 {
+		t.Errorf("Unexpected position %+v", l)
+	}
+	l = expectLine(t, lines, "two")
+
+	// rename rotation, with a last unterminated line in the old file
+	appendFile(t, path, "last")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	appendFile(t, path, "new\n")
+	expectLine(t, lines, "last")
+	l = expectLine(t, lines, "new")
+	if l.Offset != 0 || l.Number != 4 {
+		t.Errorf("Unexpected position after rotation %+v", l)
+	}
+
+	// truncation
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	appendFile(t, path, "x\n")
+	expectLine(t, lines, "x")
+
+	cancel()
+	for range lines {
+	}
+	if err := <-errc; err != nil {
+		t.Errorf("Expected no error after cancel but got %v", err)
+	}
+}
+
+func TestFollow(t *testing.T) {
+	testFollow(t, false)
+}
+
+func TestFollowPoll(t *testing.T) {
+	testFollow(t, true)
+}
+
+func TestFollowResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "a\nb\nc\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lines, _ := files.Follow(ctx, path, &files.FollowOptions{Offset: 2})
+	expectLine(t, lines, "b")
+	expectLine(t, lines, "c")
+
+	missing := filepath.Join(t.TempDir(), "later.log")
+	lines, _ = files.Follow(ctx, missing, &files.FollowOptions{Offset: -1, PollInterval: 10 * time.Millisecond})
+	time.Sleep(30 * time.Millisecond)
+	appendFile(t, missing, "first\n")
+	expectLine(t, lines, "first")
+}
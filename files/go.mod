@@ -1,3 +1,5 @@
 module github.com/Akagi201/utils-go/files
 
 go 1.18
+
+require github.com/klauspost/compress v1.16.7
@@ -0,0 +1,204 @@
+package files
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// DefaultMaxLineSize is the default LineReaderOptions.MaxLineSize.
+const DefaultMaxLineSize = 1 << 20
+
+// LineReaderOptions configures a LineReader.
+type LineReaderOptions struct {
+	// MaxLineSize is the size of the longest line accepted, in bytes.
+	// It defaults to DefaultMaxLineSize.
+	MaxLineSize int
+	// Raw disables the detection of gzip and zstd input.
+	Raw bool
+}
+
+// Line is a line read by a LineReader.
+type Line struct {
+	// Text is the line without its "\n" or "\r\n".
+	Text string
+	// Number is the 1-based line number.
+	Number int
+	// Offset is the byte offset of the start of the line in the
+	// decompressed input.
+	Offset int64
+	// Next is the offset just past the line terminator, where the next line starts.
+	Next int64
+}
+
+// LineReader reads lines like a bufio.Scanner, with line numbers and byte
+// offsets, a configurable maximum line size and transparent decompression
+// of gzip and zstd input.
+//
+//	lr, err := files.NewLineReader(f, nil)
+//	if err != nil {
+//	    return err
+//	}
+//	defer lr.Close()
+//	for lr.Scan() {
+//	    l := lr.Line()
+//	    fmt.Println(l.Number, l.Offset, l.Text)
+//	}
+//	return lr.Err()
+type LineReader struct {
+	scanner *bufio.Scanner
+	closer  io.Closer
+	line    Line
+	pos     int64
+	start   int64
+	err     error
+}
+
+// NewLineReader returns a LineReader reading from r, decompressing it if it
+// starts like gzip or zstd data. opts may be nil.
+func NewLineReader(r io.Reader, opts *LineReaderOptions) (*LineReader, error) {
+	if opts == nil {
+		opts = &LineReaderOptions{}
+	}
+	lr := &LineReader{}
+	if !opts.Raw {
+		br := bufio.NewReader(r)
+		magic, _ := br.Peek(4)
+		switch {
+		case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+			zr, err := gzip.NewReader(br)
+			if err != nil {
+				return nil, err
+			}
+			r, lr.closer = zr, zr
+		case bytes.HasPrefix(magic, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+			zr, err := zstd.NewReader(br)
+			if err != nil {
+				return nil, err
+			}
+			r, lr.closer = zr, zstdCloser{zr}
+		default:
+			r = br
+		}
+	}
+
+	max := opts.MaxLineSize
+	if max <= 0 {
+		max = DefaultMaxLineSize
+	}
+	lr.scanner = bufio.NewScanner(r)
+	// the buffer has to hold the line terminator too
+	lr.scanner.Buffer(make([]byte, 0, 4096), max+2)
+	lr.scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := bufio.ScanLines(data, atEOF)
+		if len(token) > max {
+			return 0, nil, bufio.ErrTooLong
+		}
+		if token != nil {
+			lr.start = lr.pos
+		}
+		lr.pos += int64(advance)
+		return advance, token, err
+	})
+	return lr, nil
+}
+
+// Scan advances to the next line, returning false at the end of the input
+// or on an error.
+func (lr *LineReader) Scan() bool {
+	if lr.err != nil || !lr.scanner.Scan() {
+		if lr.err == nil && lr.scanner.Err() != nil {
+			lr.err = fmt.Errorf("files: line %d: %w", lr.line.Number+1, lr.scanner.Err())
+		}
+		return false
+	}
+	lr.line = Line{Text: lr.scanner.Text(), Number: lr.line.Number + 1, Offset: lr.start, Next: lr.pos}
+	return true
+}
+
+// Line returns the line read by the last call to Scan.
+func (lr *LineReader) Line() Line {
+	return lr.line
+}
+
+// Err returns the first error met, which is nil at the end of the input.
+// Lines longer than MaxLineSize fail with an error wrapping bufio.ErrTooLong.
+func (lr *LineReader) Err() error {
+	return lr.err
+}
+
+// Close releases the decompressor. It doesn't close the underlying reader.
+func (lr *LineReader) Close() error {
+	if lr.closer != nil {
+		return lr.closer.Close()
+	}
+	return nil
+}
+
+// zstdCloser adapts zstd.Decoder, whose Close returns nothing.
+type zstdCloser struct {
+	d *zstd.Decoder
+}
+
+func (c zstdCloser) Close() error {
+	c.d.Close()
+	return nil
+}
+
+// ReadLinesContext reads a file line by line into a channel, see LineReader.
+// Both channels are closed once the file is read, it fails or ctx is done;
+// the error channel receives at most one error. Cancel ctx when you stop
+// reading early so the file gets closed.
+//
+//	ctx, cancel := context.WithCancel(ctx)
+//	defer cancel()
+//	lines, errc := files.ReadLinesContext(ctx, "access.log.gz", nil)
+//	for l := range lines {
+//	    fmt.Printf("%d: %s\n", l.Number, l.Text)
+//	}
+//	if err := <-errc; err != nil {
+//	    return err
+//	}
+func ReadLinesContext(ctx context.Context, path string, opts *LineReaderOptions) (<-chan Line, <-chan error) {
+	out := make(chan Line)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		defer close(out)
+		if err := sendLines(ctx, path, opts, out); err != nil {
+			errc <- err
+		}
+	}()
+	return out, errc
+}
+
+func sendLines(ctx context.Context, path string, opts *LineReaderOptions, out chan<- Line) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	lr, err := NewLineReader(f, opts)
+	if err != nil {
+		return err
+	}
+	defer lr.Close()
+
+	for lr.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		select {
+		case out <- lr.Line():
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return lr.Err()
+}
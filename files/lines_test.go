@@ -0,0 +1,137 @@
+package files_test
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Akagi201/utils-go/files"
+	"github.com/klauspost/compress/zstd"
+)
+
+const testLines = "first\r\nsecond\n\nfourth"
+
+func readAll(t *testing.T, data []byte, opts *files.LineReaderOptions) ([]files.Line, error) {
+	t.Helper()
+	lr, err := files.NewLineReader(bytes.NewReader(data), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lr.Close()
+	var lines []files.Line
+	for lr.Scan() {
+		lines = append(lines, lr.Line())
+	}
+	return lines, lr.Err()
+}
+
+func TestLineReader(t *testing.T) {
+	var gz, zs bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	gw.Write([]byte(testLines))
+	gw.Close()
+	zw, _ := zstd.NewWriter(&zs)
+	zw.Write([]byte(testLines))
+	zw.Close()
+
+	want := []files.Line{
+		{Text: "first", Number: 1, Offset: 0, Next: 7},
+		{Text: "second", Number: 2, Offset: 7, Next: 14},
+		{Text: "", Number: 3, Offset: 14, Next: 15},
+		{Text: "fourth", Number: 4, Offset: 15, Next: 21},
+	}
+	for name, data := range map[string][]byte{"plain": []byte(testLines), "gzip": gz.Bytes(), "zstd": zs.Bytes()} {
+		lines, err := readAll(t, data, nil)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		if len(lines) != len(want) {
+			t.Fatalf("%s: expected %d lines but got %+v", name, len(want), lines)
+		}
+		for i := range want {
+			if lines[i] != want[i] {
+				t.Errorf("%s: expected %+v but got %+v", name, want[i], lines[i])
+			}
+		}
+	}
+
+	if lines, _ := readAll(t, gz.Bytes(), &files.LineReaderOptions{Raw: true}); len(lines) == 0 || lines[0].Text == "first" {
+		t.Error("Expected Raw to disable decompression")
+	}
+}
+
+func TestReadLinesChannelRaw(t *testing.T) {
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	gw.Write([]byte(testLines))
+	gw.Close()
+	path := filepath.Join(t.TempDir(), "lines.gz")
+	if err := os.WriteFile(path, gz.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := files.ReadLinesChannel(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	for line := range c {
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 || lines[0] == "first" {
+		t.Errorf("Expected ReadLinesChannel not to decompress but got %q", lines)
+	}
+}
+
+func TestLineReaderMaxLineSize(t *testing.T) {
+	long := strings.Repeat("x", 100<<10)
+	lines, err := readAll(t, []byte("a\n"+long+"\nb\n"), nil)
+	if err != nil || len(lines) != 3 || lines[1].Text != long || lines[2].Offset != int64(len(long)+3) {
+		t.Errorf("Expected a 100KB line to be read, got %d lines (%v)", len(lines), err)
+	}
+
+	lines, err = readAll(t, []byte("abc\nabcdefgh\n"), &files.LineReaderOptions{MaxLineSize: 5})
+	if !errors.Is(err, bufio.ErrTooLong) || len(lines) != 1 {
+		t.Fatalf("Expected bufio.ErrTooLong after one line but got %v", err)
+	}
+	if !strings.HasPrefix(err.Error(), "files: line 2: ") {
+		t.Errorf("Expected the line number in %q", err)
+	}
+}
+
+func TestReadLinesContext(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lines.txt")
+	if err := os.WriteFile(path, []byte(testLines), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	lines, errc := files.ReadLinesContext(context.Background(), path, nil)
+	n := 0
+	for range lines {
+		n++
+	}
+	if err := <-errc; err != nil || n != 4 {
+		t.Errorf("Expected 4 lines but got %d (%v)", n, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	lines, errc = files.ReadLinesContext(ctx, path, nil)
+	<-lines
+	cancel()
+	for range lines {
+	}
+	if err := <-errc; err != context.Canceled {
+		t.Errorf("Expected context.Canceled but got %v", err)
+	}
+
+	_, errc = files.ReadLinesContext(context.Background(), filepath.Join(t.TempDir(), "missing"), nil)
+	if err := <-errc; !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected os.ErrNotExist but got %v", err)
+	}
+}
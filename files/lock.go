@@ -0,0 +1,144 @@
+package files
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrLocked is returned by TryLock, TryLockShared and LockPIDFile when
+// another process holds the lock.
+var ErrLocked = errors.New("files: file is locked")
+
+// FileLock is an advisory lock on a file, held with flock until Unlock.
+// Locks are per open file, so they also exclude other FileLocks in the same
+// process. They are only supported on Unix systems.
+type FileLock struct {
+	file     *os.File
+	remove   bool
+	released bool
+}
+
+// Lock takes an exclusive lock on path, creating the file if needed, and
+// waits for the other holders to release it.
+//
+//	l, err := files.Lock("data.json.lock")
+//	if err != nil {
+//	    return err
+//	}
+//	defer l.Unlock()
+func Lock(path string) (*FileLock, error) {
+	return lockPath(path, false, true)
+}
+
+// LockShared takes a shared lock on path, which excludes exclusive locks
+// only, creating the file if needed, and waits until no exclusive lock is held.
+// The file is opened read-only, so it only needs to be readable.
+func LockShared(path string) (*FileLock, error) {
+	return lockPath(path, true, true)
+}
+
+// TryLock is like Lock but fails with ErrLocked instead of waiting.
+func TryLock(path string) (*FileLock, error) {
+	return lockPath(path, false, false)
+}
+
+// TryLockShared is like LockShared but fails with ErrLocked instead of waiting.
+func TryLockShared(path string) (*FileLock, error) {
+	return lockPath(path, true, false)
+}
+
+func lockPath(path string, shared, wait bool) (*FileLock, error) {
+	var f *os.File
+	var err error
+	if shared {
+		f, err = os.Open(path)
+		if errors.Is(err, os.ErrNotExist) {
+			f, err = os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0o644)
+		}
+	} else {
+		f, err = os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := flock(f, shared, wait); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &FileLock{file: f}, nil
+}
+
+// LockPIDFile takes an exclusive lock on a PID file and writes the process
+// ID to it. It fails with ErrLocked, mentioning the PID of the holder, if the
+// file is locked. A file which isn't locked was left behind by a process
+// which is gone, even if its PID was reused since, and is taken over.
+// Unlock removes the file.
+func LockPIDFile(path string) (*FileLock, error) {
+	for {
+		l, err := TryLock(path)
+		if err != nil {
+			if errors.Is(err, ErrLocked) {
+				if pid := readPID(path); pid > 0 {
+					return nil, fmt.Errorf("%w by process %d", ErrLocked, pid)
+				}
+			}
+			return nil, err
+		}
+
+		// the file may have been removed by its previous holder between
+		// opening and locking it; then lock the new one
+		fi, ferr := l.file.Stat()
+		pi, perr := os.Stat(path)
+		if ferr != nil || perr != nil || !os.SameFile(fi, pi) {
+			l.Unlock()
+			if perr != nil && !errors.Is(perr, os.ErrNotExist) {
+				return nil, perr
+			}
+			continue
+		}
+
+		if err := l.file.Truncate(0); err != nil {
+			l.Unlock()
+			return nil, err
+		}
+		if _, err := l.file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0); err != nil {
+			l.Unlock()
+			return nil, err
+		}
+		l.remove = true
+		return l, nil
+	}
+}
+
+func readPID(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
+
+// Unlock releases the lock. For a PID file, the file is removed first.
+// Unlocking again returns os.ErrClosed and leaves the file alone, as it may
+// be locked by another process by then.
+func (l *FileLock) Unlock() error {
+	if l.released {
+		return os.ErrClosed
+	}
+	l.released = true
+	var err error
+	if l.remove {
+		err = os.Remove(l.file.Name())
+	}
+	if cerr := l.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
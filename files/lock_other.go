@@ -0,0 +1,18 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package files
+
+import (
+	"errors"
+	"os"
+)
+
+var errLockUnsupported = errors.New("files: file locking is not supported on this platform")
+
+func flock(f *os.File, shared, wait bool) error {
+	return errLockUnsupported
+}
+
+func funlock(f *os.File) error {
+	return errLockUnsupported
+}
@@ -0,0 +1,169 @@
+package files_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Akagi201/utils-go/files"
+)
+
+func skipWithoutFlock(t *testing.T) {
+	switch runtime.GOOS {
+	case "darwin", "dragonfly", "freebsd", "linux", "netbsd", "openbsd":
+	default:
+		t.Skip("file locking is not supported on " + runtime.GOOS)
+	}
+}
+
+func TestLock(t *testing.T) {
+	skipWithoutFlock(t)
+	path := filepath.Join(t.TempDir(), "data.lock")
+
+	s1, err := files.TryLockShared(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2, err := files.TryLockShared(path)
+	if err != nil {
+		t.Fatalf("Expected shared locks to be compatible but got %v", err)
+	}
+	if _, err := files.TryLock(path); !errors.Is(err, files.ErrLocked) {
+		t.Fatalf("Expected ErrLocked but got %v", err)
+	}
+	s1.Unlock()
+	s2.Unlock()
+
+	// a read-only file can be shared
+	if err := os.Chmod(path, 0o444); err != nil {
+		t.Fatal(err)
+	}
+	s1, err = files.LockShared(path)
+	if err != nil {
+		t.Fatalf("Expected a shared lock on a read-only file but got %v", err)
+	}
+	s1.Unlock()
+	os.Chmod(path, 0o644)
+
+	l, err := files.Lock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	acquired := make(chan *files.FileLock)
+	go func() {
+		l2, err := files.Lock(path)
+		if err != nil {
+			t.Error(err)
+		}
+		acquired <- l2
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("Expected Lock to wait for the holder")
+	case <-time.After(50 * time.Millisecond):
+	}
+	l.Unlock()
+	select {
+	case l2 := <-acquired:
+		l2.Unlock()
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected Lock to succeed after Unlock")
+	}
+}
+
+func TestLockPIDFile(t *testing.T) {
+	skipWithoutFlock(t)
+	path := filepath.Join(t.TempDir(), "app.pid")
+
+	l, err := files.LockPIDFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); strings.TrimSpace(string(data)) != strconv.Itoa(os.Getpid()) {
+		t.Errorf("Expected our PID in the file but found %q", data)
+	}
+	_, err = files.LockPIDFile(path)
+	if !errors.Is(err, files.ErrLocked) || !strings.Contains(err.Error(), fmt.Sprintf("by process %d", os.Getpid())) {
+		t.Errorf("Expected ErrLocked naming our PID but got %v", err)
+	}
+	if err := l.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected Unlock to remove the PID file but got %v", err)
+	}
+
+	// unlocking again mustn't remove the file locked by someone else
+	other, err := files.LockPIDFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Unlock(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Expected os.ErrClosed unlocking twice but got %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("Expected the PID file of the new holder to be kept but got %v", err)
+	}
+	other.Unlock()
+
+	// a PID file left by a process which is gone
+	if err := os.WriteFile(path, []byte("999999999\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	l, err = files.LockPIDFile(path)
+	if err != nil {
+		t.Fatalf("Expected a stale PID file to be taken over but got %v", err)
+	}
+	l.Unlock()
+
+	// the PID of a process which is gone may be reused by another one
+	if err := os.WriteFile(path, []byte(strconv.Itoa(os.Getppid())), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	l, err = files.LockPIDFile(path)
+	if err != nil {
+		t.Fatalf("Expected an unlocked PID file to be taken over but got %v", err)
+	}
+	if data, _ := os.ReadFile(path); strings.TrimSpace(string(data)) != strconv.Itoa(os.Getpid()) {
+		t.Errorf("Expected our PID in the file but found %q", data)
+	}
+	l.Unlock()
+}
+
+func TestSynchronizedFileLockFile(t *testing.T) {
+	skipWithoutFlock(t)
+	path := filepath.Join(t.TempDir(), "app.log")
+	f, err := files.OpenSynchronizedFile(path, &files.SynchronizedFileOptions{LockFile: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	l, err := files.Lock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	written := make(chan error)
+	go func() {
+		_, err := f.WriteString("hello\n")
+		written <- err
+	}()
+	select {
+	case <-written:
+		t.Fatal("Expected the write to wait for the lock")
+	case <-time.After(50 * time.Millisecond):
+	}
+	l.Unlock()
+	if err := <-written; err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "hello\n" {
+		t.Errorf("Unexpected content %q", data)
+	}
+}
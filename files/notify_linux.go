@@ -0,0 +1,123 @@
+package files
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const notifyMask = syscall.IN_MODIFY | syscall.IN_ATTRIB | syscall.IN_CLOSE_WRITE |
+	syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_DELETE_SELF |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_MOVE_SELF
+
+// notifier watches directories with inotify.
+type notifier struct {
+	f  *os.File
+	fd int
+	fn func(path string, mask uint32)
+
+	mu      sync.Mutex
+	watches map[int32]string
+}
+
+// newNotifier starts an inotify instance which calls fn, from its own
+// goroutine, with the path and inotify mask of every event in the watched
+// directories.
+func newNotifier(fn func(path string, mask uint32)) (*notifier, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	// a non-blocking fd goes through the runtime poller, so Close interrupts Read
+	n := &notifier{
+		f:       os.NewFile(uintptr(fd), "inotify"),
+		fd:      fd,
+		fn:      fn,
+		watches: map[int32]string{},
+	}
+	go n.readEvents()
+	return n, nil
+}
+
+// add watches a directory and the files in it.
+func (n *notifier) add(dir string) error {
+	wd, err := syscall.InotifyAddWatch(n.fd, dir, notifyMask)
+	if err != nil {
+		return &os.PathError{Op: "inotify_add_watch", Path: dir, Err: err}
+	}
+	n.mu.Lock()
+	n.watches[int32(wd)] = dir
+	n.mu.Unlock()
+	return nil
+}
+
+// remove stops watching a directory and the directories below it.
+func (n *notifier) remove(dir string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for wd, d := range n.watches {
+		if d == dir || strings.HasPrefix(d, dir+string(filepath.Separator)) {
+			syscall.InotifyRmWatch(n.fd, uint32(wd))
+			delete(n.watches, wd)
+		}
+	}
+}
+
+// notifyOp translates an inotify mask to the operation of an Event, zero
+// for the events Watch ignores. isDir is set for events about a directory,
+// and self for those about a watched directory itself rather than its content.
+func notifyOp(mask uint32) (op Op, isDir, self bool) {
+	switch {
+	case mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0:
+		op = Create
+	case mask&(syscall.IN_MODIFY|syscall.IN_CLOSE_WRITE) != 0:
+		op = Write
+	case mask&(syscall.IN_DELETE|syscall.IN_DELETE_SELF) != 0:
+		op = Remove
+	case mask&(syscall.IN_MOVED_FROM|syscall.IN_MOVE_SELF) != 0:
+		op = Rename
+	}
+	return op, mask&syscall.IN_ISDIR != 0, mask&(syscall.IN_DELETE_SELF|syscall.IN_MOVE_SELF) != 0
+}
+
+func (n *notifier) close() error {
+	return n.f.Close()
+}
+
+func (n *notifier) readEvents() {
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		k, err := n.f.Read(buf)
+		if err != nil {
+			return
+		}
+		for off := 0; off+syscall.SizeofInotifyEvent <= k; {
+			ev := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			nameBytes := buf[off+syscall.SizeofInotifyEvent : off+syscall.SizeofInotifyEvent+int(ev.Len)]
+			off += syscall.SizeofInotifyEvent + int(ev.Len)
+
+			n.mu.Lock()
+			dir, ok := n.watches[ev.Wd]
+			if ev.Mask&syscall.IN_IGNORED != 0 {
+				delete(n.watches, ev.Wd)
+			}
+			n.mu.Unlock()
+			if !ok || ev.Mask&syscall.IN_IGNORED != 0 {
+				continue
+			}
+
+			path := dir
+			if i := bytes.IndexByte(nameBytes, 0); i >= 0 {
+				nameBytes = nameBytes[:i]
+			}
+			if len(nameBytes) > 0 {
+				path = filepath.Join(dir, string(nameBytes))
+			}
+			n.fn(path, ev.Mask)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+
+package files
+
+import "errors"
+
+// notifier is only implemented with inotify; elsewhere callers poll.
+type notifier struct{}
+
+func newNotifier(fn func(path string, mask uint32)) (*notifier, error) {
+	return nil, errors.New("files: file notifications are not supported on this platform")
+}
+
+func (n *notifier) add(dir string) error { return nil }
+
+func (n *notifier) remove(dir string) {}
+
+func (n *notifier) close() error { return nil }
+
+func notifyOp(mask uint32) (op Op, isDir, self bool) { return 0, false, false }
@@ -0,0 +1,332 @@
+package files
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Properties is a Java .properties file. It keeps the order of the keys and
+// the comments and blank lines between them, and entries which aren't
+// changed are written back exactly as they were read.
+//
+//	p, err := files.LoadProperties("app.properties")
+//	if err != nil {
+//	    return err
+//	}
+//	p.Set("maxRows", "50")
+//	err = p.Save("app.properties")
+//
+// Files are read and written as UTF-8; \uXXXX escapes are understood but
+// only produced for control characters and, as UTF-16 surrogate pairs like
+// Java does, for characters above U+FFFF such as emoji.
+type Properties struct {
+	lines []propertyLine
+}
+
+// propertyLine is a key and value, or a comment or blank line when isEntry is false.
+type propertyLine struct {
+	isEntry bool
+	key     string
+	value   string
+	// raw is the text as read, including continuation lines. It is cleared
+	// when the value changes.
+	raw string
+}
+
+// PropertiesError is a syntax error in a .properties file.
+type PropertiesError struct {
+	// Line is the 1-based line number the erroneous entry starts on.
+	Line int
+	Msg  string
+}
+
+func (e *PropertiesError) Error() string {
+	return fmt.Sprintf("files: properties line %d: %s", e.Line, e.Msg)
+}
+
+// NewProperties returns an empty Properties.
+func NewProperties() *Properties {
+	return &Properties{}
+}
+
+// LoadProperties reads a .properties file.
+func LoadProperties(path string) (*Properties, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseProperties(f)
+}
+
+// ParseProperties reads the .properties format: key/value separators are
+// '=', ':' or whitespace, comments start with '#' or '!', a backslash at the
+// end of a line continues it on the next one, and keys and values may use
+// the \t, \n, \r, \f and \uXXXX escapes. A key which appears several times
+// gets its last value.
+func ParseProperties(r io.Reader) (*Properties, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	natural := splitNaturalLines(string(data))
+
+	p := &Properties{}
+	for i := 0; i < len(natural); i++ {
+		lineNo := i + 1
+		line := strings.TrimLeft(natural[i], " \t\f")
+		if line == "" || line[0] == '#' || line[0] == '!' {
+			p.lines = append(p.lines, propertyLine{raw: natural[i]})
+			continue
+		}
+
+		raw := natural[i]
+		logical := line
+		for continues(logical) && i+1 < len(natural) {
+			i++
+			raw += "\n" + natural[i]
+			logical = logical[:len(logical)-1] + strings.TrimLeft(natural[i], " \t\f")
+		}
+		if continues(logical) {
+			// a continuation at the end of the input continues into nothing
+			logical = logical[:len(logical)-1]
+		}
+
+		keyEnd := splitKey(logical)
+		key, err := unescapeProperty(logical[:keyEnd])
+		if err != nil {
+			return nil, &PropertiesError{Line: lineNo, Msg: err.Error()}
+		}
+		rest := strings.TrimLeft(logical[keyEnd:], " \t\f")
+		if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+			rest = strings.TrimLeft(rest[1:], " \t\f")
+		}
+		value, err := unescapeProperty(rest)
+		if err != nil {
+			return nil, &PropertiesError{Line: lineNo, Msg: err.Error()}
+		}
+		p.lines = append(p.lines, propertyLine{isEntry: true, key: key, value: value, raw: raw})
+	}
+	return p, nil
+}
+
+// splitNaturalLines splits on "\n", "\r\n" and "\r".
+func splitNaturalLines(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// continues reports whether a line ends with an odd number of backslashes.
+func continues(line string) bool {
+	n := 0
+	for i := len(line) - 1; i >= 0 && line[i] == '\\'; i-- {
+		n++
+	}
+	return n%2 == 1
+}
+
+// splitKey returns the end of the key: the first unescaped separator or whitespace.
+func splitKey(line string) int {
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '=', ':', ' ', '\t', '\f':
+			return i
+		}
+	}
+	return len(line)
+}
+
+func unescapeProperty(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		if i == len(s) {
+			break
+		}
+		switch s[i] {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'f':
+			b.WriteByte('\f')
+		case 'u':
+			if i+5 > len(s) {
+				return "", fmt.Errorf("malformed \\uXXXX escape %q", s[i-1:])
+			}
+			r, err := strconv.ParseUint(s[i+1:i+5], 16, 16)
+			if err != nil {
+				return "", fmt.Errorf("malformed \\uXXXX escape %q", s[i-1:i+5])
+			}
+			i += 4
+			// characters above U+FFFF are escaped as UTF-16 surrogate pairs
+			if utf16.IsSurrogate(rune(r)) && strings.HasPrefix(s[i+1:], `\u`) && i+7 <= len(s) {
+				if r2, err := strconv.ParseUint(s[i+3:i+7], 16, 16); err == nil {
+					if dr := utf16.DecodeRune(rune(r), rune(r2)); dr != utf8.RuneError {
+						b.WriteRune(dr)
+						i += 6
+						continue
+					}
+				}
+			}
+			b.WriteRune(rune(r))
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), nil
+}
+
+// Get returns the value of a key.
+func (p *Properties) Get(key string) (string, bool) {
+	for i := len(p.lines) - 1; i >= 0; i-- {
+		if l := p.lines[i]; l.isEntry && l.key == key {
+			return l.value, true
+		}
+	}
+	return "", false
+}
+
+// Set changes the value of a key, or appends it if it is new.
+func (p *Properties) Set(key, value string) {
+	for i := len(p.lines) - 1; i >= 0; i-- {
+		if l := &p.lines[i]; l.isEntry && l.key == key {
+			if l.value != value {
+				l.value, l.raw = value, ""
+			}
+			return
+		}
+	}
+	p.lines = append(p.lines, propertyLine{isEntry: true, key: key, value: value})
+}
+
+// Delete removes every occurrence of a key. Its comments stay.
+func (p *Properties) Delete(key string) {
+	keep := p.lines[:0]
+	for _, l := range p.lines {
+		if !l.isEntry || l.key != key {
+			keep = append(keep, l)
+		}
+	}
+	p.lines = keep
+}
+
+// AddComment appends a comment; each line of text becomes a "# " line.
+func (p *Properties) AddComment(text string) {
+	for _, line := range strings.Split(text, "\n") {
+		p.lines = append(p.lines, propertyLine{raw: strings.TrimRight("# "+line, " ")})
+	}
+}
+
+// Keys returns the keys in the order they first appear.
+func (p *Properties) Keys() []string {
+	seen := map[string]bool{}
+	var keys []string
+	for _, l := range p.lines {
+		if l.isEntry && !seen[l.key] {
+			seen[l.key] = true
+			keys = append(keys, l.key)
+		}
+	}
+	return keys
+}
+
+// Map returns the keys and their values.
+func (p *Properties) Map() map[string]string {
+	m := make(map[string]string)
+	for _, l := range p.lines {
+		if l.isEntry {
+			m[l.key] = l.value
+		}
+	}
+	return m
+}
+
+// WriteTo writes the properties in the .properties format, with "\n" line endings.
+func (p *Properties) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	var n int64
+	for _, l := range p.lines {
+		s := l.raw
+		if l.isEntry && s == "" {
+			s = escapeProperty(l.key, true) + "=" + escapeProperty(l.value, false)
+		}
+		m, err := bw.WriteString(s + "\n")
+		n += int64(m)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, bw.Flush()
+}
+
+// Save writes the properties to a file atomically, see WriteAtomic.
+func (p *Properties) Save(path string) error {
+	var b strings.Builder
+	// writing to a strings.Builder can't fail
+	_, _ = p.WriteTo(&b)
+	return WriteAtomic(path, []byte(b.String()), 0o644)
+}
+
+// escapeProperty escapes a key or value. Spaces only need escaping in keys
+// and at the start of values.
+func escapeProperty(s string, isKey bool) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch r {
+		case '\\':
+			b.WriteString(`\\`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\f':
+			b.WriteString(`\f`)
+		case ' ':
+			if isKey || i == 0 {
+				b.WriteByte('\\')
+			}
+			b.WriteByte(' ')
+		case '=', ':', '#', '!':
+			if isKey {
+				b.WriteByte('\\')
+			}
+			b.WriteRune(r)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else if r > 0xffff {
+				r1, r2 := utf16.EncodeRune(r)
+				fmt.Fprintf(&b, `\u%04X\u%04X`, r1, r2)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,142 @@
+package files_test
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Akagi201/utils-go/files"
+)
+
+const testProperties = `# Application settings
+! legacy comment
+maxRows = 20
+name:Jane Doe
+path   C:\\temp\\app
+greeting = Hello, \
+           World!
+unicode = caf\u00e9
+key\ with\ spaces = a\=b
+empty
+tab\tkey = \ leading space
+maxRows = 30
+`
+
+func TestParseProperties(t *testing.T) {
+	p, err := files.ParseProperties(strings.NewReader(testProperties))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"maxRows":         "30",
+		"name":            "Jane Doe",
+		"path":            `C:\temp\app`,
+		"greeting":        "Hello, World!",
+		"unicode":         "café",
+		"key with spaces": "a=b",
+		"empty":           "",
+		"tab\tkey":        " leading space",
+	}
+	if got := p.Map(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected properties %q", got)
+	}
+	wantKeys := []string{"maxRows", "name", "path", "greeting", "unicode", "key with spaces", "empty", "tab\tkey"}
+	if keys := p.Keys(); !reflect.DeepEqual(keys, wantKeys) {
+		t.Errorf("Unexpected keys %q", keys)
+	}
+}
+
+func TestPropertiesRoundTrip(t *testing.T) {
+	p, err := files.ParseProperties(strings.NewReader(testProperties))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	if _, err := p.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != testProperties {
+		t.Errorf("Expected an unchanged round trip but got\n%s", b.String())
+	}
+
+	p.Set("maxRows", "50")
+	p.Set("greeting", "Hi")
+	p.Delete("empty")
+	p.AddComment("added\nby test")
+	p.Set("new key", "#1: ok\n")
+	b.Reset()
+	p.WriteTo(&b)
+	want := `# Application settings
+! legacy comment
+maxRows = 20
+name:Jane Doe
+path   C:\\temp\\app
+greeting=Hi
+unicode = caf\u00e9
+key\ with\ spaces = a\=b
+tab\tkey = \ leading space
+maxRows=50
+# added
+# by test
+new\ key=#1: ok\n
+`
+	if b.String() != want {
+		t.Errorf("Expected\n%s\nbut got\n%s", want, b.String())
+	}
+
+	path := filepath.Join(t.TempDir(), "app.properties")
+	if err := p.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := files.LoadProperties(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.Map(), p.Map()) {
+		t.Errorf("Expected %q after reloading but got %q", p.Map(), loaded.Map())
+	}
+	if v, _ := loaded.Get("new key"); v != "#1: ok\n" {
+		t.Errorf("Unexpected value %q", v)
+	}
+}
+
+func TestPropertiesSurrogatePairs(t *testing.T) {
+	p, err := files.ParseProperties(strings.NewReader("smile = \\uD83D\\uDE00 \\u00e9\nlone = \\uD83D!\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := p.Get("smile"); v != "\U0001F600 é" {
+		t.Errorf("Unexpected value %q", v)
+	}
+	if v, _ := p.Get("lone"); v != "\uFFFD!" {
+		t.Errorf("Unexpected value %q", v)
+	}
+
+	p.Set("smile", "hi \U0001F600")
+	var b strings.Builder
+	p.WriteTo(&b)
+	if !strings.Contains(b.String(), `smile=hi \uD83D\uDE00`) {
+		t.Errorf("Expected a surrogate pair in\n%s", b.String())
+	}
+	loaded, err := files.ParseProperties(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := loaded.Get("smile"); v != "hi \U0001F600" {
+		t.Errorf("Unexpected value %q after a round trip", v)
+	}
+}
+
+func TestParsePropertiesError(t *testing.T) {
+	_, err := files.ParseProperties(strings.NewReader("a = 1\n# comment\nb = \\u12G4\n"))
+	var pe *files.PropertiesError
+	if !errors.As(err, &pe) || pe.Line != 3 {
+		t.Fatalf("Expected an error on line 3 but got %v", err)
+	}
+	if !strings.HasPrefix(err.Error(), "files: properties line 3: malformed \\uXXXX escape") {
+		t.Errorf("Unexpected message %q", err)
+	}
+}
@@ -1,32 +1,398 @@
 package files
 
 import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 )
 
-// SynchronizedFile wraps an os.File pointer with a mutex
+// SynchronizedFile wraps an os.File pointer with a mutex. It is an io.Writer
+// which may be used from several goroutines, e.g. by a log.Logger, and can
+// buffer writes and rotate the file, see OpenSynchronizedFile.
 type SynchronizedFile struct {
 	file  *os.File
 	mutex sync.Mutex
+
+	path   string
+	opts   SynchronizedFileOptions
+	buf    *bufio.Writer
+	size   int64
+	opened time.Time
+	closed bool
+
+	stop      chan struct{}
+	wg        sync.WaitGroup
+	cleanupMu sync.Mutex
 }
 
+// SynchronizedFileOptions configures OpenSynchronizedFile.
+type SynchronizedFileOptions struct {
+	// BufferSize enables buffered writes when positive. Buffered data is
+	// written on Flush, Sync, Close, rotation and every FlushInterval.
+	BufferSize    int
+	FlushInterval time.Duration
+
+	// MaxSize rotates the file before a write would make it larger, in bytes.
+	MaxSize int64
+	// RotateInterval rotates the file once it has been written to for that
+	// long, counting from the first write. Empty files aren't rotated.
+	RotateInterval time.Duration
+	// Compress gzips rotated segments.
+	Compress bool
+	// MaxBackups is the number of rotated segments to keep, MaxAge how long
+	// to keep them. Zero keeps them all.
+	MaxBackups int
+	MaxAge     time.Duration
+	// LockFile holds an exclusive flock on the file, along with the mutex,
+	// while writing to it, so that processes appending to the same file
+	// don't interleave their writes. With buffering, this is when the buffer
+	// is written. Rotation isn't coordinated between processes.
+	LockFile bool
+	// OnError receives the errors of background flushes, compressions and
+	// removals. They are dropped when it is nil.
+	OnError func(error)
+}
+
+// backupTimeFormat is the timestamp in the name of rotated segments, as in
+// app-20060102T150405.000.log.
+const backupTimeFormat = "20060102T150405.000"
+
 // NewSynchronizedFile synchronizes writing to a writer
 func NewSynchronizedFile(f *os.File) *SynchronizedFile {
-	sf := &SynchronizedFile{file: f}
+	sf := &SynchronizedFile{file: f, path: f.Name(), opened: time.Now()}
 	return sf
 }
 
+// OpenSynchronizedFile opens path for appending, creating it if needed.
+// opts may be nil.
+//
+//	f, err := files.OpenSynchronizedFile("app.log", &files.SynchronizedFileOptions{
+//	    BufferSize:    64 << 10,
+//	    FlushInterval: time.Second,
+//	    MaxSize:       100 << 20,
+//	    Compress:      true,
+//	    MaxBackups:    7,
+//	})
+//	if err != nil {
+//	    return err
+//	}
+//	defer f.Close()
+//	logger := log.New(f, "", log.LstdFlags)
+//
+// Rotated segments are renamed with their rotation time, app.log becoming
+// app-20221030T181500.000.log, or app-20221030T181500.000.log.gz once compressed.
+func OpenSynchronizedFile(path string, opts *SynchronizedFileOptions) (*SynchronizedFile, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	sf := &SynchronizedFile{file: f, path: path, size: info.Size(), opened: time.Now()}
+	if opts != nil {
+		sf.opts = *opts
+	}
+	if sf.opts.BufferSize > 0 {
+		sf.buf = bufio.NewWriterSize(sf.writer(), sf.opts.BufferSize)
+		if sf.opts.FlushInterval > 0 {
+			sf.stop = make(chan struct{})
+			sf.wg.Add(1)
+			go sf.flushLoop()
+		}
+	}
+	return sf, nil
+}
+
+// Write writes to the file, rotating it first if needed.
+func (sf *SynchronizedFile) Write(p []byte) (int, error) {
+	sf.mutex.Lock()
+	defer sf.mutex.Unlock()
+	return sf.write(p)
+}
+
 // WriteString writes to the file
 func (sf *SynchronizedFile) WriteString(text string) (int, error) {
 	sf.mutex.Lock()
 	defer sf.mutex.Unlock()
-	return sf.file.WriteString(text)
+	return sf.write([]byte(text))
+}
+
+func (sf *SynchronizedFile) write(p []byte) (int, error) {
+	if sf.closed {
+		return 0, os.ErrClosed
+	}
+	if sf.needsRotation(int64(len(p))) {
+		if err := sf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	if sf.size == 0 {
+		sf.opened = time.Now()
+	}
+	w := sf.writer()
+	if sf.buf != nil {
+		w = sf.buf
+	}
+	n, err := w.Write(p)
+	sf.size += int64(n)
+	return n, err
+}
+
+// Flush writes the buffered data to the file.
+func (sf *SynchronizedFile) Flush() error {
+	sf.mutex.Lock()
+	defer sf.mutex.Unlock()
+	return sf.flush()
+}
+
+func (sf *SynchronizedFile) flush() error {
+	if sf.closed {
+		return os.ErrClosed
+	}
+	if sf.buf == nil {
+		return nil
+	}
+	return sf.buf.Flush()
+}
+
+// Sync flushes the buffered data and commits the file to disk.
+func (sf *SynchronizedFile) Sync() error {
+	sf.mutex.Lock()
+	defer sf.mutex.Unlock()
+	if err := sf.flush(); err != nil {
+		return err
+	}
+	return sf.file.Sync()
+}
+
+// Rotate renames the file and continues with a new one, see OpenSynchronizedFile.
+func (sf *SynchronizedFile) Rotate() error {
+	sf.mutex.Lock()
+	defer sf.mutex.Unlock()
+	if sf.closed {
+		return os.ErrClosed
+	}
+	return sf.rotate()
 }
 
 // Close closes the file
 func (sf *SynchronizedFile) Close() error {
 	sf.mutex.Lock()
-	defer sf.mutex.Unlock()
-	return sf.file.Close()
+	if sf.closed {
+		sf.mutex.Unlock()
+		return os.ErrClosed
+	}
+	err := sf.flush()
+	if cerr := sf.file.Close(); err == nil {
+		err = cerr
+	}
+	sf.closed = true
+	sf.mutex.Unlock()
+
+	if sf.stop != nil {
+		close(sf.stop)
+	}
+	// wait for the flush loop and the compressions
+	sf.wg.Wait()
+	return err
+}
+
+// writer returns the file, wrapped to lock it around each write if needed.
+func (sf *SynchronizedFile) writer() io.Writer {
+	if sf.opts.LockFile {
+		return lockedWriter{sf.file}
+	}
+	return sf.file
+}
+
+// lockedWriter holds an exclusive flock on a file during each write.
+type lockedWriter struct {
+	f *os.File
+}
+
+func (w lockedWriter) Write(p []byte) (int, error) {
+	if err := flock(w.f, false, true); err != nil {
+		return 0, err
+	}
+	n, err := w.f.Write(p)
+	if uerr := funlock(w.f); err == nil {
+		err = uerr
+	}
+	return n, err
+}
+
+func (sf *SynchronizedFile) flushLoop() {
+	defer sf.wg.Done()
+	t := time.NewTicker(sf.opts.FlushInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-sf.stop:
+			return
+		case <-t.C:
+			sf.mutex.Lock()
+			err := sf.flush()
+			sf.mutex.Unlock()
+			if err != nil && err != os.ErrClosed {
+				sf.reportError(err)
+			}
+		}
+	}
+}
+
+func (sf *SynchronizedFile) needsRotation(n int64) bool {
+	if sf.opts.MaxSize > 0 && sf.size > 0 && sf.size+n > sf.opts.MaxSize {
+		return true
+	}
+	return sf.opts.RotateInterval > 0 && sf.size > 0 && time.Since(sf.opened) >= sf.opts.RotateInterval
+}
+
+func (sf *SynchronizedFile) rotate() error {
+	if sf.buf != nil {
+		if err := sf.buf.Flush(); err != nil {
+			return err
+		}
+	}
+	mode := os.FileMode(0o644)
+	if info, err := sf.file.Stat(); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	now := time.Now()
+	ext := filepath.Ext(sf.path)
+	var backup string
+	for stamp := now; ; stamp = stamp.Add(time.Millisecond) {
+		// never overwrite a segment rotated within the same millisecond
+		backup = strings.TrimSuffix(sf.path, ext) + "-" + stamp.Format(backupTimeFormat) + ext
+		if !exists(backup) && !exists(backup+".gz") {
+			break
+		}
+	}
+	if err := os.Rename(sf.path, backup); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(sf.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, mode)
+	if err != nil {
+		// carry on with the current file, under its name if possible
+		if rerr := os.Rename(backup, sf.path); rerr != nil {
+			return fmt.Errorf("files: rotating %s: %w; the file is left as %s: %v", sf.path, err, backup, rerr)
+		}
+		return err
+	}
+	sf.file.Close()
+	sf.file, sf.size, sf.opened = f, 0, now
+	if sf.buf != nil {
+		sf.buf.Reset(sf.writer())
+	}
+
+	sf.wg.Add(1)
+	go func() {
+		defer sf.wg.Done()
+		sf.cleanupMu.Lock()
+		defer sf.cleanupMu.Unlock()
+		if sf.opts.Compress {
+			// the segment may have been removed by the retention of a later rotation
+			if err := compressFile(backup); err != nil && !errors.Is(err, os.ErrNotExist) {
+				sf.reportError(err)
+			}
+		}
+		sf.removeBackups()
+	}()
+	return nil
+}
+
+// compressFile replaces a file by its gzipped version.
+func compressFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	w, err := NewAtomicWriter(path+".gz", info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	defer w.Abort()
+	zw := gzip.NewWriter(w)
+	if _, err := io.Copy(zw, in); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// removeBackups applies MaxBackups and MaxAge to the rotated segments.
+func (sf *SynchronizedFile) removeBackups() {
+	if sf.opts.MaxBackups <= 0 && sf.opts.MaxAge <= 0 {
+		return
+	}
+	dir := filepath.Dir(sf.path)
+	ext := filepath.Ext(sf.path)
+	prefix := strings.TrimSuffix(filepath.Base(sf.path), ext) + "-"
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		sf.reportError(err)
+		return
+	}
+	type backup struct {
+		name string
+		t    time.Time
+	}
+	var backups []backup
+	for _, e := range entries {
+		name := e.Name()
+		stamp := strings.TrimSuffix(strings.TrimSuffix(name, ".gz"), ext)
+		if !strings.HasPrefix(stamp, prefix) {
+			continue
+		}
+		t, err := time.ParseInLocation(backupTimeFormat, stamp[len(prefix):], time.Local)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup{name, t})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].t.After(backups[j].t) })
+
+	for i, b := range backups {
+		if sf.opts.MaxBackups > 0 && i >= sf.opts.MaxBackups ||
+			sf.opts.MaxAge > 0 && time.Since(b.t) > sf.opts.MaxAge {
+			if err := os.Remove(filepath.Join(dir, b.name)); err != nil {
+				sf.reportError(err)
+			}
+		}
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+func (sf *SynchronizedFile) reportError(err error) {
+	if sf.opts.OnError != nil {
+		sf.opts.OnError(err)
+	}
 }
@@ -0,0 +1,159 @@
+package files_test
+
+import (
+	"compress/gzip"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Akagi201/utils-go/files"
+)
+
+func TestSynchronizedFileWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	f, err := files.OpenSynchronizedFile(path, &files.SynchronizedFileOptions{BufferSize: 4096})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var w io.Writer = f
+	logger := log.New(w, "", 0)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				logger.Println("0123456789")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if data, _ := os.ReadFile(path); len(data) >= 1000*11 {
+		t.Errorf("Expected buffered data not to be written yet but found %d bytes", len(data))
+	}
+	if err := f.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if len(data) != 1000*11 || strings.Count(string(data), "0123456789\n") != 1000 {
+		t.Errorf("Expected 1000 complete lines but got %d bytes", len(data))
+	}
+
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("x")); err != os.ErrClosed {
+		t.Errorf("Expected os.ErrClosed but got %v", err)
+	}
+}
+
+func TestSynchronizedFileFlushInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	f, err := files.OpenSynchronizedFile(path, &files.SynchronizedFileOptions{BufferSize: 4096, FlushInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	f.WriteString("hello\n")
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if data, _ := os.ReadFile(path); string(data) == "hello\n" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the buffer to be flushed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSynchronizedFileRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	f, err := files.OpenSynchronizedFile(path, &files.SynchronizedFileOptions{
+		MaxSize:    20,
+		Compress:   true,
+		MaxBackups: 2,
+		OnError:    func(err error) { t.Error(err) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"line one\n", "line two\n", "line three\n", "line four\n", "line five\n", "line six\n"} {
+		if _, err := f.WriteString(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	var backups []string
+	for _, e := range entries {
+		if e.Name() != "app.log" {
+			backups = append(backups, e.Name())
+		}
+	}
+	sort.Strings(backups)
+	if len(backups) != 2 {
+		t.Fatalf("Expected 2 backups but found %q", backups)
+	}
+	for _, b := range backups {
+		if !strings.HasPrefix(b, "app-") || !strings.HasSuffix(b, ".log.gz") {
+			t.Errorf("Unexpected backup name %s", b)
+		}
+	}
+
+	// the newest backup holds the lines before the current file
+	gz, err := os.Open(filepath.Join(dir, backups[1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gz.Close()
+	zr, err := gzip.NewReader(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(zr)
+	if string(data) != "line four\nline five\n" {
+		t.Errorf("Unexpected backup content %q", data)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "line six\n" {
+		t.Errorf("Unexpected current content %q", data)
+	}
+}
+
+func TestSynchronizedFileRotateInterval(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	f, err := files.OpenSynchronizedFile(path, &files.SynchronizedFileOptions{RotateInterval: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// the interval starts with the first write
+	time.Sleep(30 * time.Millisecond)
+	f.WriteString("a\n")
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("Expected an empty file not to be rotated, found %d entries", len(entries))
+	}
+	time.Sleep(30 * time.Millisecond)
+	f.WriteString("b\n")
+	if data, _ := os.ReadFile(path); string(data) != "b\n" {
+		t.Errorf("Expected the file to be rotated but found %q", data)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Errorf("Expected a backup next to the file, found %d entries", len(entries))
+	}
+}
@@ -0,0 +1,44 @@
+package files
+
+import (
+	"errors"
+	"os"
+)
+
+// CreateTemp creates a new file like os.CreateTemp, opened for reading and
+// writing, and returns a function which closes and removes it.
+//
+//	f, remove, err := files.CreateTemp("", "upload-*.tar")
+//	if err != nil {
+//	    return err
+//	}
+//	defer remove()
+func CreateTemp(dir, pattern string) (*os.File, func() error, error) {
+	f, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return nil, nil, err
+	}
+	remove := func() error {
+		err := f.Close()
+		if errors.Is(err, os.ErrClosed) {
+			err = nil
+		}
+		if rerr := os.Remove(f.Name()); rerr != nil && !errors.Is(rerr, os.ErrNotExist) && err == nil {
+			err = rerr
+		}
+		return err
+	}
+	return f, remove, nil
+}
+
+// TempDir creates a new directory like os.MkdirTemp and returns a function
+// which removes it with its content.
+//
+// For tests, see the filestest package.
+func TempDir(dir, pattern string) (string, func() error, error) {
+	name, err := os.MkdirTemp(dir, pattern)
+	if err != nil {
+		return "", nil, err
+	}
+	return name, func() error { return os.RemoveAll(name) }, nil
+}
@@ -0,0 +1,63 @@
+package files_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Akagi201/utils-go/files"
+)
+
+func TestExecutableDir(t *testing.T) {
+	dir, err := files.ExecutableDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exe, _ = filepath.EvalSymlinks(exe)
+	if !filepath.IsAbs(dir) || dir != filepath.Dir(exe) {
+		t.Errorf("Expected %s but got %s", filepath.Dir(exe), dir)
+	}
+}
+
+func TestCreateTemp(t *testing.T) {
+	dir := t.TempDir()
+	f, remove, err := files.CreateTemp(dir, "data-*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if base := filepath.Base(f.Name()); !strings.HasPrefix(base, "data-") || !strings.HasSuffix(base, ".txt") {
+		t.Errorf("Unexpected name %s", base)
+	}
+	if _, err := f.WriteString("x"); err != nil {
+		t.Fatal(err)
+	}
+	if err := remove(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(f.Name()); !os.IsNotExist(err) {
+		t.Errorf("Expected the file to be removed but got %v", err)
+	}
+	// closed and removed already
+	if err := remove(); err != nil {
+		t.Errorf("Expected no error removing twice but got %v", err)
+	}
+}
+
+func TestTempDir(t *testing.T) {
+	dir, remove, err := files.TempDir(t.TempDir(), "work-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	makeTree(t, dir, map[string]string{"a/b": "c"})
+	if err := remove(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("Expected the directory to be removed but got %v", err)
+	}
+}
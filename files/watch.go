@@ -0,0 +1,400 @@
+package files
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Op is the set of operations of an Event.
+type Op uint32
+
+// Operations of an Event
+const (
+	// Create is a new file or directory, including one renamed or moved to
+	// its path.
+	Create Op = 1 << iota
+	// Write is a change of a file's content.
+	Write
+	// Remove is a file or directory which was deleted.
+	Remove
+	// Rename is a file or directory which was renamed or moved away from its
+	// path. Polling reports it as Remove.
+	Rename
+)
+
+// Has reports whether op includes o.
+func (op Op) Has(o Op) bool {
+	return op&o != 0
+}
+
+func (op Op) String() string {
+	var names []string
+	for _, o := range []struct {
+		op   Op
+		name string
+	}{{Create, "CREATE"}, {Write, "WRITE"}, {Remove, "REMOVE"}, {Rename, "RENAME"}} {
+		if op.Has(o.op) {
+			names = append(names, o.name)
+		}
+	}
+	if len(names) == 0 {
+		return "0"
+	}
+	return strings.Join(names, "|")
+}
+
+// Event is a change of a watched file. Op holds all the operations which
+// happened to Path during the debounce period.
+type Event struct {
+	Path string
+	Op   Op
+}
+
+// Watcher watches files and directories for changes. The zero value is
+// ready to use; set its fields before calling Watch.
+type Watcher struct {
+	// Recursive watches the whole tree of the watched directories, including
+	// the directories created later, instead of their direct content only.
+	Recursive bool
+	// Debounce is how long a path has to stay quiet before its event is
+	// sent, merging bursts of writes into one event. It defaults to 100ms;
+	// a negative value sends every change as it comes.
+	Debounce time.Duration
+	// Poll disables inotify and scans the paths every PollInterval,
+	// comparing sizes, modification times and identities of the files.
+	// Polling is used when inotify isn't available.
+	Poll bool
+	// PollInterval defaults to 500ms.
+	PollInterval time.Duration
+}
+
+// Watch watches files and directories recursively, see Watcher.
+//
+//	events, errc := files.Watch(ctx, "config.yaml", "conf.d")
+//	for ev := range events {
+//	    if ev.Op.Has(files.Create | files.Write) {
+//	        reload()
+//	    }
+//	}
+//	if err := <-errc; err != nil {
+//	    return err
+//	}
+func Watch(ctx context.Context, paths ...string) (<-chan Event, <-chan error) {
+	w := &Watcher{Recursive: true}
+	return w.Watch(ctx, paths...)
+}
+
+// Watch sends the changes of the given paths. A watched file may not exist
+// yet, as long as its directory does; a watched directory reports the
+// changes of its content and its own removal or renaming. Events are sent
+// in path order when several are due at once.
+//
+// Both channels are closed when ctx is done or watching fails; the error
+// channel receives at most one error, which is nil when ctx ends the watch.
+func (w *Watcher) Watch(ctx context.Context, paths ...string) (<-chan Event, <-chan error) {
+	out := make(chan Event)
+	errc := make(chan error, 1)
+	ws := &watchState{
+		Watcher: *w,
+		out:     out,
+		raw:     make(chan Event, 256),
+		done:    make(chan struct{}),
+		pending: map[string]Op{},
+		last:    map[string]time.Time{},
+	}
+	if ws.Debounce == 0 {
+		ws.Debounce = 100 * time.Millisecond
+	}
+	if ws.PollInterval <= 0 {
+		ws.PollInterval = 500 * time.Millisecond
+	}
+
+	// start watching before returning, so that no later change is missed
+	err := ws.init(paths)
+	go func() {
+		defer close(errc)
+		defer close(out)
+		if ws.notifier != nil {
+			defer ws.notifier.close()
+		}
+		defer close(ws.done)
+		if err == nil {
+			err = ws.run(ctx)
+		}
+		if err != nil && ctx.Err() == nil {
+			errc <- err
+		}
+	}()
+	return out, errc
+}
+
+// watchRoot is a path given to Watch.
+type watchRoot struct {
+	path  string
+	isDir bool
+}
+
+// fileStamp is what polling compares to detect changes.
+type fileStamp struct {
+	info fs.FileInfo
+}
+
+func (s fileStamp) changed(o fileStamp) bool {
+	return !s.info.IsDir() &&
+		(s.info.Size() != o.info.Size() || !s.info.ModTime().Equal(o.info.ModTime()))
+}
+
+type watchState struct {
+	Watcher
+	roots []watchRoot
+	out   chan<- Event
+
+	notifier *notifier
+	raw      chan Event
+	done     chan struct{}
+	files    map[string]fileStamp // when polling
+
+	pending map[string]Op
+	last    map[string]time.Time
+}
+
+func (ws *watchState) init(paths []string) error {
+	for _, p := range paths {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return err
+		}
+		root := watchRoot{path: abs}
+		info, err := os.Stat(abs)
+		switch {
+		case err == nil:
+			root.isDir = info.IsDir()
+		case errors.Is(err, fs.ErrNotExist):
+			// a file to come: its directory has to be there
+			if _, err := os.Stat(filepath.Dir(abs)); err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+		ws.roots = append(ws.roots, root)
+	}
+
+	if !ws.Poll {
+		n, err := newNotifier(ws.notified)
+		if err == nil {
+			ws.notifier = n
+			for _, r := range ws.roots {
+				if !r.isDir {
+					err = n.add(filepath.Dir(r.path))
+				} else {
+					err = ws.addTree(r.path, nil)
+				}
+				if err != nil {
+					n.close()
+					ws.notifier = nil
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	ws.Poll = true
+	ws.files = ws.scan()
+	return nil
+}
+
+// addTree watches a directory and, when recursive, its subdirectories.
+// created receives the paths found below it.
+func (ws *watchState) addTree(dir string, created func(path string)) error {
+	return Walk(dir, func(p string, info fs.FileInfo, err error) error {
+		if err != nil {
+			// removed in the meantime
+			if errors.Is(err, fs.ErrNotExist) && p != dir {
+				return nil
+			}
+			return err
+		}
+		if p != dir && created != nil {
+			created(p)
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if p != dir && !ws.Recursive {
+			return filepath.SkipDir
+		}
+		return ws.notifier.add(p)
+	}, nil)
+}
+
+// notified runs in the notifier's goroutine.
+func (ws *watchState) notified(path string, mask uint32) {
+	op, isDir, self := notifyOp(mask)
+	if op == 0 || self && !ws.isRoot(path) || !ws.watched(path) {
+		return
+	}
+	if isDir && ws.Recursive {
+		switch op {
+		case Create:
+			// watch the new directory, and report what was created in it
+			// before the watch started
+			ws.addTree(path, func(p string) {
+				ws.send(Event{Path: p, Op: Create})
+			})
+		case Rename:
+			ws.notifier.remove(path)
+		}
+	}
+	ws.send(Event{Path: path, Op: op})
+}
+
+// send passes an event from the notifier to run.
+func (ws *watchState) send(ev Event) {
+	select {
+	case ws.raw <- ev:
+	case <-ws.done:
+	}
+}
+
+func (ws *watchState) isRoot(path string) bool {
+	for _, r := range ws.roots {
+		if r.path == path {
+			return true
+		}
+	}
+	return false
+}
+
+// watched reports whether a path is one of the roots or in one of their
+// directories.
+func (ws *watchState) watched(path string) bool {
+	for _, r := range ws.roots {
+		switch {
+		case path == r.path:
+			return true
+		case !r.isDir:
+		case ws.Recursive && strings.HasPrefix(path, r.path+string(filepath.Separator)):
+			return true
+		case filepath.Dir(path) == r.path:
+			return true
+		}
+	}
+	return false
+}
+
+func (ws *watchState) run(ctx context.Context) error {
+	var tick <-chan time.Time
+	if ws.Poll {
+		ticker := time.NewTicker(ws.PollInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case ev := <-ws.raw:
+			ws.add(ev)
+		case <-tick:
+			files := ws.scan()
+			for _, ev := range diffScans(ws.files, files) {
+				ws.add(ev)
+			}
+			ws.files = files
+		case <-timer.C:
+		}
+
+		due, next := ws.due()
+		for _, ev := range due {
+			select {
+			case ws.out <- ev:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if next > 0 {
+			timer.Reset(next)
+		}
+	}
+}
+
+func (ws *watchState) add(ev Event) {
+	ws.pending[ev.Path] |= ev.Op
+	ws.last[ev.Path] = time.Now()
+}
+
+// due returns the events whose path has been quiet long enough, and how long
+// to wait for the next one, zero if none is pending.
+func (ws *watchState) due() ([]Event, time.Duration) {
+	var due []Event
+	var next time.Duration
+	now := time.Now()
+	for p, op := range ws.pending {
+		wait := ws.Debounce - now.Sub(ws.last[p])
+		if ws.Debounce < 0 || wait <= 0 {
+			due = append(due, Event{Path: p, Op: op})
+			delete(ws.pending, p)
+			delete(ws.last, p)
+			continue
+		}
+		if next == 0 || wait < next {
+			next = wait
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].Path < due[j].Path })
+	return due, next
+}
+
+// scan lists the watched paths, when polling.
+func (ws *watchState) scan() map[string]fileStamp {
+	files := map[string]fileStamp{}
+	for _, r := range ws.roots {
+		Walk(r.path, func(p string, info fs.FileInfo, err error) error {
+			if err != nil {
+				// gone in the meantime
+				return nil
+			}
+			files[p] = fileStamp{info}
+			if info.IsDir() && p != r.path && !ws.Recursive {
+				return filepath.SkipDir
+			}
+			if info.IsDir() && !r.isDir {
+				// a watched file replaced by a directory
+				return filepath.SkipDir
+			}
+			return nil
+		}, nil)
+	}
+	return files
+}
+
+// diffScans returns the changes between two scans.
+func diffScans(old, cur map[string]fileStamp) []Event {
+	var events []Event
+	for p, s := range cur {
+		o, ok := old[p]
+		switch {
+		case !ok, !os.SameFile(o.info, s.info):
+			events = append(events, Event{Path: p, Op: Create})
+		case s.changed(o):
+			events = append(events, Event{Path: p, Op: Write})
+		}
+	}
+	for p := range old {
+		if _, ok := cur[p]; !ok {
+			events = append(events, Event{Path: p, Op: Remove})
+		}
+	}
+	return events
+}
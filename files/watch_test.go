@@ -0,0 +1,129 @@
+package files_test
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Akagi201/utils-go/files"
+)
+
+func expectEvent(t *testing.T, events <-chan files.Event, path string, op files.Op) {
+	t.Helper()
+	select {
+	case ev, ok := <-events:
+		if !ok {
+			t.Fatalf("Expected %s %s but the channel is closed", op, path)
+		}
+		if ev.Path != path || !ev.Op.Has(op) {
+			t.Fatalf("Expected %s %s but got %s %s", op, path, ev.Op, ev.Path)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for %s %s", op, path)
+	}
+}
+
+func expectNoEvent(t *testing.T, events <-chan files.Event) {
+	t.Helper()
+	select {
+	case ev := <-events:
+		t.Fatalf("Unexpected event %s %s", ev.Op, ev.Path)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func testWatch(t *testing.T, poll bool) {
+	dir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := &files.Watcher{Recursive: true, Debounce: 50 * time.Millisecond, Poll: poll, PollInterval: 10 * time.Millisecond}
+	events, errc := w.Watch(ctx, dir)
+
+	conf := filepath.Join(dir, "a.conf")
+	appendFile(t, conf, "a")
+	expectEvent(t, events, conf, files.Create)
+
+	// a burst of writes makes one event
+	for i := 0; i < 5; i++ {
+		appendFile(t, conf, "b")
+		time.Sleep(5 * time.Millisecond)
+	}
+	expectEvent(t, events, conf, files.Write)
+	expectNoEvent(t, events)
+
+	// new directories are watched too
+	sub := filepath.Join(dir, "sub")
+	if err := os.Mkdir(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, filepath.Join(sub, "b"), "b")
+	expectEvent(t, events, sub, files.Create)
+	expectEvent(t, events, filepath.Join(sub, "b"), files.Create)
+	time.Sleep(100 * time.Millisecond)
+	appendFile(t, filepath.Join(sub, "b"), "b")
+	expectEvent(t, events, filepath.Join(sub, "b"), files.Write)
+
+	if err := os.Rename(filepath.Join(sub, "b"), filepath.Join(sub, "c")); err != nil {
+		t.Fatal(err)
+	}
+	expectEvent(t, events, filepath.Join(sub, "b"), files.Rename|files.Remove)
+	expectEvent(t, events, filepath.Join(sub, "c"), files.Create)
+
+	if err := os.Remove(conf); err != nil {
+		t.Fatal(err)
+	}
+	expectEvent(t, events, conf, files.Remove)
+
+	cancel()
+	for range events {
+	}
+	if err := <-errc; err != nil {
+		t.Errorf("Expected no error but got %v", err)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	testWatch(t, false)
+}
+
+func TestWatchPoll(t *testing.T) {
+	testWatch(t, true)
+}
+
+func TestWatchFile(t *testing.T) {
+	for _, poll := range []bool{false, true} {
+		dir := t.TempDir()
+		ctx, cancel := context.WithCancel(context.Background())
+		w := &files.Watcher{Debounce: 20 * time.Millisecond, Poll: poll, PollInterval: 10 * time.Millisecond}
+		conf := filepath.Join(dir, "config.yaml")
+		events, _ := w.Watch(ctx, conf)
+
+		appendFile(t, filepath.Join(dir, "other"), "x")
+		expectNoEvent(t, events)
+		appendFile(t, conf, "x")
+		expectEvent(t, events, conf, files.Create)
+
+		// replaced like editors and config management do
+		tmp := filepath.Join(dir, ".config.yaml.tmp")
+		appendFile(t, tmp, "y")
+		if err := os.Rename(tmp, conf); err != nil {
+			t.Fatal(err)
+		}
+		expectEvent(t, events, conf, files.Create)
+		cancel()
+	}
+}
+
+func TestWatchMissing(t *testing.T) {
+	events, errc := files.Watch(context.Background(), filepath.Join(t.TempDir(), "missing", "dir"))
+	if _, ok := <-events; ok {
+		t.Error("Expected the events channel to be closed")
+	}
+	if err := <-errc; !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected fs.ErrNotExist but got %v", err)
+	}
+}
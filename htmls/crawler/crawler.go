@@ -0,0 +1,609 @@
+// Package crawler is a concurrent web crawler built on the htmls package.
+//
+// It fetches pages with per-host concurrency and rate limits, honours
+// robots.txt, follows links up to a depth and through URL filters, never
+// visits a URL twice and retries temporary failures. Every HTML page is
+// parsed and handed to a callback as an *html.Node.
+//
+//	c := &crawler.Crawler{
+//	    MaxDepth: 2,
+//	    Filters:  []crawler.Filter{crawler.SameHost("https://example.com/")},
+//	    OnPage: func(p *crawler.Page) error {
+//	        if title, ok := htmls.Find(p.Root, htmls.ByTag(atom.Title)); ok {
+//	            fmt.Println(p.URL, htmls.Text(title))
+//	        }
+//	        return nil
+//	    },
+//	}
+//	err := c.Run(ctx, "https://example.com/")
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Akagi201/utils-go/htmls"
+	"golang.org/x/net/html"
+)
+
+// SkipLinks can be returned by OnPage to not follow the links of a page.
+var SkipLinks = errors.New("skip links")
+
+// ErrRobots is passed to OnError for URLs disallowed by robots.txt.
+var ErrRobots = errors.New("crawler: disallowed by robots.txt")
+
+// StatusError is passed to OnError when a page can't be fetched because of its status code.
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("crawler: GET %s: %d %s", e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// Page is a fetched and parsed HTML page.
+type Page struct {
+	// URL is the URL of the page. Redirects are followed like links, keeping
+	// the Referrer and Depth of the redirecting URL.
+	URL *url.URL
+	// Referrer is the page the URL was found on, nil for seeds.
+	Referrer *url.URL
+	// Depth is the number of links followed from a seed, which has depth 0.
+	Depth      int
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	Root       *html.Node
+}
+
+// Filter decides whether a discovered link is followed.
+type Filter func(u *url.URL, depth int) bool
+
+// SameHost returns a Filter which only follows links to the hosts of the given URLs.
+func SameHost(urls ...string) Filter {
+	hosts := map[string]bool{}
+	for _, s := range urls {
+		if u, err := url.Parse(s); err == nil {
+			hosts[strings.ToLower(u.Host)] = true
+		}
+	}
+	return func(u *url.URL, _ int) bool {
+		return hosts[u.Host]
+	}
+}
+
+// MatchRegexp returns a Filter which only follows links whose URL matches re.
+func MatchRegexp(re *regexp.Regexp) Filter {
+	return func(u *url.URL, _ int) bool {
+		return re.MatchString(u.String())
+	}
+}
+
+// Crawler holds the crawl settings. The zero value crawls the seeds only,
+// one request at a time per host, respecting robots.txt.
+//
+// A Crawler may be Run several times, also concurrently; each Run starts
+// with no visited URLs.
+type Crawler struct {
+	// Client makes the requests. If nil, http.DefaultClient is used.
+	Client *http.Client
+	// UserAgent is sent with every request and matched against robots.txt groups.
+	// It defaults to "utils-go-crawler".
+	UserAgent string
+
+	// MaxDepth is how many links are followed from a seed: 0 crawls the
+	// seeds only and a negative value has no limit.
+	MaxDepth int
+	// Filters must all return true for a discovered link to be followed.
+	// Seeds are not filtered. Only http and https links are ever followed.
+	Filters []Filter
+	// IgnoreRobots disables robots.txt checks.
+	IgnoreRobots bool
+
+	// Concurrency is the maximum number of requests in flight. It defaults to 8.
+	Concurrency int
+	// HostConcurrency is the maximum number of requests in flight per host. It defaults to 1.
+	HostConcurrency int
+	// HostDelay is the minimum time between the starts of two requests to
+	// the same host. A longer robots.txt Crawl-delay takes precedence.
+	HostDelay time.Duration
+
+	// Retries is how many times a request failing with a network error, 429
+	// or a 5xx status is retried.
+	Retries int
+	// RetryDelay is the wait before the first retry, doubling after each
+	// attempt. It defaults to one second.
+	RetryDelay time.Duration
+	// MaxBodySize limits how much of a response is read. It defaults to 10 MiB.
+	MaxBodySize int64
+
+	// OnPage is called, possibly concurrently, for every HTML page. Returning
+	// SkipLinks doesn't follow the links of the page; any other error stops
+	// the crawl and is returned by Run.
+	OnPage func(p *Page) error
+	// OnError is called, possibly concurrently, with the URLs which couldn't be
+	// crawled: fetch errors, *StatusError and ErrRobots. It may be nil.
+	OnError func(u *url.URL, err error)
+}
+
+// Run crawls from the seeds until there are no more links to follow, OnPage
+// returns an error or ctx is done, and returns the OnPage error or ctx.Err().
+// Seeds must be absolute http or https URLs; nothing is fetched otherwise.
+//
+// Pages are fetched by Concurrency workers taking URLs from a queue, so a
+// large site costs one small queue entry per discovered URL, not a goroutine.
+// Redirects are queued like links, at the depth of the redirecting page, so
+// their targets go through Filters, robots.txt and the host limits too.
+func (c *Crawler) Run(ctx context.Context, seeds ...string) error {
+	urls := make([]*url.URL, 0, len(seeds))
+	for _, s := range seeds {
+		u, err := url.Parse(s)
+		if err != nil {
+			return err
+		}
+		if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+			return fmt.Errorf("crawler: seed %q is not an absolute http or https URL", s)
+		}
+		urls = append(urls, u)
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	cr := &crawl{
+		Crawler: c,
+		ctx:     runCtx,
+		cancel:  cancel,
+		seen:    map[string]bool{},
+		hosts:   map[string]*host{},
+		robots:  map[string]*robotsEntry{},
+	}
+	cr.cond = sync.NewCond(&cr.mu)
+	// handle redirects as links, see visit
+	client := *c.client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	cr.httpClient = &client
+
+	for _, u := range urls {
+		cr.enqueue(task{u: u})
+	}
+
+	// wake the idle workers up when the crawl is canceled
+	go func() {
+		<-runCtx.Done()
+		cr.mu.Lock()
+		cr.cond.Broadcast()
+		cr.mu.Unlock()
+	}()
+	var wg sync.WaitGroup
+	for i := 0; i < orDefault(c.Concurrency, 8); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cr.work()
+		}()
+	}
+	wg.Wait()
+
+	if cr.err != nil {
+		return cr.err
+	}
+	return ctx.Err()
+}
+
+// maxRedirects is the length of the longest redirect chain followed.
+const maxRedirects = 10
+
+// task is a URL to crawl.
+type task struct {
+	u, referrer *url.URL
+	depth       int
+	redirects   int       // the number of redirects which led to u
+	attempts    int       // the failed attempts to fetch u
+	notBefore   time.Time // when u may be retried
+}
+
+// crawl is the state of a single Run. Tasks are queued per host, and a
+// worker only takes a task whose host accepts a request right away, so that
+// busy or rate limited hosts never hold workers up.
+type crawl struct {
+	*Crawler
+	ctx        context.Context
+	cancel     context.CancelFunc
+	httpClient *http.Client
+
+	mu     sync.Mutex
+	cond   *sync.Cond // signaled when a task may be ready or the crawl ends
+	hosts  map[string]*host
+	order  []*host // the hosts, served round robin
+	turn   int     // the index in order of the next host served
+	queued int     // the number of tasks in the host queues
+	active int     // the number of tasks being visited
+	seen   map[string]bool
+	robots map[string]*robotsEntry
+	err    error
+}
+
+// host is the queue and the limits of a single host, guarded by crawl.mu.
+type host struct {
+	queue    []task
+	inFlight int       // the number of tasks being visited
+	next     time.Time // when the next request may start
+	delay    time.Duration
+}
+
+// slot is the right to make requests to a host, held by a worker for a
+// task, with its first request already scheduled.
+type slot struct {
+	*host
+	scheduled bool
+}
+
+// robotsEntry is the robots.txt of a scheme and host, fetched once.
+type robotsEntry struct {
+	once   sync.Once
+	robots *robots
+}
+
+// wait blocks until the next request to the host may start.
+func (cr *crawl) wait(s *slot) error {
+	if s.scheduled {
+		s.scheduled = false
+		return cr.ctx.Err()
+	}
+	cr.mu.Lock()
+	now := time.Now()
+	start := s.next
+	if start.Before(now) {
+		start = now
+	}
+	s.next = start.Add(s.delay)
+	cr.mu.Unlock()
+	return sleep(cr.ctx, start.Sub(now))
+}
+
+// robotsFor returns the robots.txt rules for u, fetching them on first use.
+func (cr *crawl) robotsFor(s *slot, u *url.URL) *robots {
+	key := u.Scheme + "://" + u.Host
+	cr.mu.Lock()
+	e, ok := cr.robots[key]
+	if !ok {
+		e = &robotsEntry{}
+		cr.robots[key] = e
+	}
+	cr.mu.Unlock()
+	e.once.Do(func() { e.robots = cr.fetchRobots(s, u) })
+	return e.robots
+}
+
+func (cr *crawl) stop(err error) {
+	cr.mu.Lock()
+	if cr.err == nil {
+		cr.err = err
+	}
+	cr.mu.Unlock()
+	cr.cancel()
+}
+
+// enqueue queues a URL unless it was seen already or isn't followed.
+func (cr *crawl) enqueue(t task) {
+	t.u = normalize(t.u)
+	if t.u.Scheme != "http" && t.u.Scheme != "https" {
+		return
+	}
+	if t.referrer != nil {
+		if cr.MaxDepth >= 0 && t.depth > cr.MaxDepth {
+			return
+		}
+		for _, f := range cr.Filters {
+			if !f(t.u, t.depth) {
+				return
+			}
+		}
+	}
+
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	key := t.u.String()
+	if cr.seen[key] {
+		return
+	}
+	cr.seen[key] = true
+	cr.push(t)
+}
+
+// push adds a task to the queue of its host. cr.mu must be held.
+func (cr *crawl) push(t task) {
+	h, ok := cr.hosts[t.u.Host]
+	if !ok {
+		h = &host{delay: cr.HostDelay}
+		cr.hosts[t.u.Host] = h
+		cr.order = append(cr.order, h)
+	}
+	h.queue = append(h.queue, t)
+	cr.queued++
+	cr.cond.Signal()
+}
+
+// take removes a task which may be visited now from the queues, and
+// schedules its first request. Otherwise, it returns when a task may be
+// ready, or the zero time if it depends on the tasks being visited.
+// cr.mu must be held.
+func (cr *crawl) take(now time.Time) (task, *slot, bool, time.Time) {
+	var wake time.Time
+	later := func(t time.Time) {
+		if wake.IsZero() || t.Before(wake) {
+			wake = t
+		}
+	}
+	limit := orDefault(cr.HostConcurrency, 1)
+	for i := range cr.order {
+		h := cr.order[(cr.turn+i)%len(cr.order)]
+		if len(h.queue) == 0 || h.inFlight >= limit {
+			continue
+		}
+		if h.next.After(now) {
+			later(h.next)
+			continue
+		}
+		for j, t := range h.queue {
+			if t.notBefore.After(now) {
+				later(t.notBefore)
+				continue
+			}
+			if j == 0 {
+				h.queue[0] = task{}
+				h.queue = h.queue[1:]
+			} else {
+				h.queue = append(h.queue[:j], h.queue[j+1:]...)
+			}
+			cr.queued--
+			cr.turn = (cr.turn + i + 1) % len(cr.order)
+			h.inFlight++
+			h.next = now.Add(h.delay)
+			return t, &slot{host: h, scheduled: true}, true, time.Time{}
+		}
+	}
+	return task{}, nil, false, wake
+}
+
+// work visits the queued URLs until the queues are empty and no other worker
+// may add to them, or the crawl is canceled.
+func (cr *crawl) work() {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	for cr.ctx.Err() == nil {
+		t, s, ok, wake := cr.take(time.Now())
+		if !ok {
+			if cr.queued == 0 && cr.active == 0 {
+				break
+			}
+			cr.waitTask(wake)
+			continue
+		}
+
+		cr.active++
+		cr.mu.Unlock()
+		cr.visit(t, s)
+		cr.mu.Lock()
+		cr.active--
+		s.inFlight--
+		// the host may take another task, or the crawl be over
+		cr.cond.Broadcast()
+	}
+	// wake the other workers up so they see it too
+	cr.cond.Broadcast()
+}
+
+// waitTask waits for cr.cond, or until wake unless it is zero. cr.mu must
+// be held.
+func (cr *crawl) waitTask(wake time.Time) {
+	if !wake.IsZero() {
+		timer := time.AfterFunc(time.Until(wake), func() {
+			cr.mu.Lock()
+			cr.cond.Broadcast()
+			cr.mu.Unlock()
+		})
+		defer timer.Stop()
+	}
+	cr.cond.Wait()
+}
+
+func (cr *crawl) visit(t task, s *slot) {
+	u := t.u
+	if !cr.IgnoreRobots && !cr.robotsFor(s, u).allowed(u) {
+		if cr.ctx.Err() == nil {
+			cr.reportError(u, ErrRobots)
+		}
+		return
+	}
+
+	resp, body, err := cr.get(s, u)
+	if err != nil {
+		if cr.ctx.Err() != nil {
+			return
+		}
+		if temporary(err) && t.attempts < cr.Retries {
+			// retry later, leaving the worker and the host to other URLs;
+			// jitter keeps the retries of several URLs apart
+			delay := orDefault(cr.RetryDelay, time.Second) << t.attempts
+			t.attempts++
+			t.notBefore = time.Now().Add(delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1)))
+			cr.mu.Lock()
+			cr.push(t)
+			cr.mu.Unlock()
+			return
+		}
+		cr.reportError(u, err)
+		return
+	}
+
+	if isRedirect(resp) {
+		loc, err := resp.Location()
+		switch {
+		case err != nil:
+			cr.reportError(u, err)
+		case t.redirects >= maxRedirects:
+			cr.reportError(u, fmt.Errorf("crawler: GET %s: stopped after %d redirects", u, maxRedirects))
+		default:
+			cr.enqueue(task{u: loc, referrer: t.referrer, depth: t.depth, redirects: t.redirects + 1})
+		}
+		return
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "" {
+		if mt, _, _ := mime.ParseMediaType(ct); mt != "text/html" && mt != "application/xhtml+xml" {
+			return
+		}
+	}
+	root, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		cr.reportError(u, err)
+		return
+	}
+
+	p := &Page{
+		URL:        u,
+		Referrer:   t.referrer,
+		Depth:      t.depth,
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       body,
+		Root:       root,
+	}
+	if cr.OnPage != nil {
+		if err := cr.OnPage(p); err == SkipLinks {
+			return
+		} else if err != nil {
+			cr.stop(err)
+			return
+		}
+	}
+	if cr.MaxDepth >= 0 && t.depth >= cr.MaxDepth {
+		return
+	}
+	for _, l := range htmls.Links(root, u) {
+		if containsString(l.Rel, "nofollow") {
+			continue
+		}
+		cr.enqueue(task{u: l.URL, referrer: u, depth: t.depth + 1})
+	}
+}
+
+// isRedirect reports whether resp redirects to its Location.
+func isRedirect(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return resp.Header.Get("Location") != ""
+	}
+	return false
+}
+
+func (cr *crawl) get(s *slot, u *url.URL) (*http.Response, []byte, error) {
+	if err := cr.wait(s); err != nil {
+		return nil, nil, err
+	}
+	req, err := http.NewRequestWithContext(cr.ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("User-Agent", cr.userAgent())
+	resp, err := cr.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, orDefault(cr.MaxBodySize, 10<<20)))
+	if err != nil {
+		return nil, nil, err
+	}
+	if (resp.StatusCode < 200 || resp.StatusCode > 299) && !isRedirect(resp) {
+		return nil, nil, &StatusError{URL: u.String(), StatusCode: resp.StatusCode}
+	}
+	return resp, body, nil
+}
+
+func (cr *crawl) reportError(u *url.URL, err error) {
+	if cr.OnError != nil {
+		cr.OnError(u, err)
+	}
+}
+
+func (c *Crawler) client() *http.Client {
+	if c.Client != nil {
+		return c.Client
+	}
+	return http.DefaultClient
+}
+
+func (c *Crawler) userAgent() string {
+	if c.UserAgent != "" {
+		return c.UserAgent
+	}
+	return "utils-go-crawler"
+}
+
+// temporary reports whether a failed request is worth retrying.
+func temporary(err error) bool {
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.StatusCode == http.StatusTooManyRequests || se.StatusCode >= 500
+	}
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+// normalize returns u without its fragment and with a lower case scheme and host.
+func normalize(u *url.URL) *url.URL {
+	n := *u
+	n.Fragment = ""
+	n.RawFragment = ""
+	n.Scheme = strings.ToLower(n.Scheme)
+	n.Host = strings.ToLower(n.Host)
+	if n.Path == "" && n.Opaque == "" {
+		n.Path = "/"
+	}
+	return &n
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func orDefault[T int | int64 | time.Duration](v, def T) T {
+	if v <= 0 {
+		return def
+	}
+	return v
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,419 @@
+package crawler_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Akagi201/utils-go/htmls"
+	"github.com/Akagi201/utils-go/htmls/crawler"
+	"golang.org/x/net/html/atom"
+)
+
+const testRobots = `
+User-agent: *
+Disallow: /
+
+# the crawler's own group
+User-agent: TestBot
+Disallow: /private
+Allow: /private/ok$
+`
+
+var testPages = map[string]string{
+	"/":           `<title>home</title><a href="/a">a</a><a href="b#top">b</a><a href="/a">again</a><a href="/private/x">x</a><a href="/private/ok">ok</a><a href="http://elsewhere.invalid/">out</a><a href="mailto:a@b.c">mail</a>`,
+	"/a":          `<title>a</title><a href="/c">c</a><a href="/">home</a>`,
+	"/b":          `<title>b</title><a href="/flaky">flaky</a><a href="/gone">gone</a>`,
+	"/c":          `<title>c</title><a href="/d">d</a>`,
+	"/d":          `<title>d</title>`,
+	"/flaky":      `<title>flaky</title>`,
+	"/private/ok": `<title>ok</title>`,
+	"/private/x":  `<title>x</title>`,
+}
+
+func newTestServer(t *testing.T) (*httptest.Server, *sync.Map) {
+	hits := &sync.Map{}
+	var flaky int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := hits.LoadOrStore(r.URL.Path, new(int32))
+		atomic.AddInt32(n.(*int32), 1)
+
+		if r.URL.Path == "/robots.txt" {
+			fmt.Fprint(w, testRobots)
+			return
+		}
+		if r.URL.Path == "/flaky" && atomic.AddInt32(&flaky, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		page, ok := testPages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, page)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, hits
+}
+
+func TestRun(t *testing.T) {
+	srv, hits := newTestServer(t)
+
+	var mu sync.Mutex
+	var pages, failed []string
+	c := &crawler.Crawler{
+		UserAgent:  "TestBot/1.0",
+		MaxDepth:   2,
+		Filters:    []crawler.Filter{crawler.SameHost(srv.URL)},
+		Retries:    1,
+		RetryDelay: time.Millisecond,
+		OnPage: func(p *crawler.Page) error {
+			title, _ := htmls.Find(p.Root, htmls.ByTag(atom.Title))
+			mu.Lock()
+			pages = append(pages, fmt.Sprintf("%s %d %s", p.URL.Path, p.Depth, htmls.Text(title)))
+			mu.Unlock()
+			return nil
+		},
+		OnError: func(u *url.URL, err error) {
+			mu.Lock()
+			failed = append(failed, u.Path+" "+err.Error())
+			mu.Unlock()
+		},
+	}
+	if err := c.Run(context.Background(), srv.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Strings(pages)
+	want := []string{"/ 0 home", "/a 1 a", "/b 1 b", "/c 2 c", "/flaky 2 flaky", "/private/ok 1 ok"}
+	if strings.Join(pages, "|") != strings.Join(want, "|") {
+		t.Errorf("Unexpected pages %q", pages)
+	}
+
+	sort.Strings(failed)
+	wantFailed := []string{
+		"/gone crawler: GET " + srv.URL + "/gone: 404 Not Found",
+		"/private/x " + crawler.ErrRobots.Error(),
+	}
+	if strings.Join(failed, "|") != strings.Join(wantFailed, "|") {
+		t.Errorf("Unexpected errors %q", failed)
+	}
+
+	counts := map[string]int32{}
+	hits.Range(func(k, v any) bool {
+		counts[k.(string)] = atomic.LoadInt32(v.(*int32))
+		return true
+	})
+	for path, want := range map[string]int32{"/robots.txt": 1, "/": 1, "/a": 1, "/flaky": 2, "/d": 0, "/private/x": 0} {
+		if counts[path] != want {
+			t.Errorf("Expected %d requests for %s but got %d", want, path, counts[path])
+		}
+	}
+}
+
+func TestRunHostLimits(t *testing.T) {
+	var inFlight, maxInFlight int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			m := atomic.LoadInt32(&maxInFlight)
+			if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/" {
+			for i := 0; i < 8; i++ {
+				fmt.Fprintf(w, `<a href="/%d">%d</a>`, i, i)
+			}
+		}
+	}))
+	defer srv.Close()
+
+	c := &crawler.Crawler{
+		MaxDepth:        1,
+		IgnoreRobots:    true,
+		HostConcurrency: 2,
+	}
+	if err := c.Run(context.Background(), srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	if maxInFlight != 2 {
+		t.Errorf("Expected at most 2 concurrent requests but got %d", maxInFlight)
+	}
+
+	c = &crawler.Crawler{
+		MaxDepth:        1,
+		IgnoreRobots:    true,
+		HostConcurrency: 4,
+		HostDelay:       30 * time.Millisecond,
+	}
+	start := time.Now()
+	if err := c.Run(context.Background(), srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	// 9 requests started at least 30ms apart
+	if elapsed := time.Since(start); elapsed < 8*30*time.Millisecond {
+		t.Errorf("Expected requests to be spaced by HostDelay but the crawl took %s", elapsed)
+	}
+}
+
+func TestRunScheduling(t *testing.T) {
+	type request struct {
+		host, path string
+		at         time.Duration
+	}
+	var mu sync.Mutex
+	var requests []request
+	start := time.Now()
+	handler := func(name string, delay time.Duration) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(delay)
+			mu.Lock()
+			requests = append(requests, request{name, r.URL.Path, time.Since(start)})
+			mu.Unlock()
+			if r.URL.Path == "/fail" {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("Content-Type", "text/html")
+			if r.URL.Path == "/" {
+				fmt.Fprint(w, `<a href="/fail">fail</a>`)
+				for i := 0; i < 4; i++ {
+					fmt.Fprintf(w, `<a href="/%d">%d</a>`, i, i)
+				}
+			}
+		}
+	}
+	slow := httptest.NewServer(handler("slow", 50*time.Millisecond))
+	defer slow.Close()
+	fast := httptest.NewServer(handler("fast", 0))
+	defer fast.Close()
+
+	// retried after at least 500ms
+	c := &crawler.Crawler{
+		MaxDepth:     1,
+		IgnoreRobots: true,
+		Concurrency:  2,
+		Retries:      1,
+		RetryDelay:   time.Second,
+	}
+	if err := c.Run(context.Background(), slow.URL, fast.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(requests) != 14 {
+		t.Fatalf("Expected 14 requests but got %v", requests)
+	}
+	for _, r := range requests {
+		switch {
+		case r.path == "/fail":
+		case r.host == "fast" && r.at > 100*time.Millisecond:
+			t.Errorf("Expected the fast host not to wait for the slow one: %v", requests)
+		case r.host == "slow" && r.at > 450*time.Millisecond:
+			t.Errorf("Expected the pages of a host not to wait for a retry: %v", requests)
+		}
+	}
+}
+
+func TestRunStop(t *testing.T) {
+	srv, hits := newTestServer(t)
+
+	var n int32
+	c := &crawler.Crawler{
+		UserAgent: "TestBot",
+		MaxDepth:  -1,
+		OnPage: func(p *crawler.Page) error {
+			atomic.AddInt32(&n, 1)
+			return crawler.SkipLinks
+		},
+	}
+	if err := c.Run(context.Background(), srv.URL+"/a"); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("Expected SkipLinks to only visit the seed but got %d pages", n)
+	}
+
+	errStop := errors.New("stop")
+	c.OnPage = func(p *crawler.Page) error {
+		return errStop
+	}
+	if err := c.Run(context.Background(), srv.URL); err != errStop {
+		t.Errorf("Expected the OnPage error but got %v", err)
+	}
+	if v, _ := hits.Load("/c"); v != nil {
+		t.Errorf("Expected the crawl to stop but /c was fetched")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.Run(ctx, srv.URL); err != context.Canceled {
+		t.Errorf("Expected context.Canceled but got %v", err)
+	}
+}
+
+func TestRunSeeds(t *testing.T) {
+	srv, hits := newTestServer(t)
+	c := &crawler.Crawler{UserAgent: "TestBot"}
+	for _, seed := range []string{"%zz", "/relative", "ftp://example.com/"} {
+		if err := c.Run(context.Background(), srv.URL, seed); err == nil {
+			t.Errorf("Expected an error for seed %q", seed)
+		}
+	}
+	hits.Range(func(k, _ any) bool {
+		t.Errorf("Expected no requests with an invalid seed but %s was fetched", k)
+		return true
+	})
+}
+
+func TestRunRedirects(t *testing.T) {
+	var mu sync.Mutex
+	hits := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.URL.Path]++
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/robots.txt":
+			fmt.Fprint(w, "User-agent: *\nDisallow: /private\n")
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<a href="/old">old</a><a href="/hidden">hidden</a><a href="/skip">skip</a>`)
+		case "/old":
+			http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+		case "/hidden":
+			http.Redirect(w, r, "/private/page", http.StatusFound)
+		case "/skip":
+			http.Redirect(w, r, "/filtered", http.StatusFound)
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<title>page</title>`)
+		}
+	}))
+	defer srv.Close()
+
+	var pages, failed []string
+	c := &crawler.Crawler{
+		MaxDepth: 1,
+		Filters: []crawler.Filter{func(u *url.URL, _ int) bool {
+			return u.Path != "/filtered"
+		}},
+		OnPage: func(p *crawler.Page) error {
+			mu.Lock()
+			pages = append(pages, fmt.Sprintf("%s %d", p.URL.Path, p.Depth))
+			mu.Unlock()
+			return nil
+		},
+		OnError: func(u *url.URL, err error) {
+			mu.Lock()
+			failed = append(failed, u.Path+" "+err.Error())
+			mu.Unlock()
+		},
+	}
+	if err := c.Run(context.Background(), srv.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Strings(pages)
+	if strings.Join(pages, "|") != "/ 0|/new 1" {
+		t.Errorf("Unexpected pages %q", pages)
+	}
+	if strings.Join(failed, "|") != "/private/page "+crawler.ErrRobots.Error() {
+		t.Errorf("Unexpected errors %q", failed)
+	}
+	if hits["/private/page"] != 0 || hits["/filtered"] != 0 {
+		t.Errorf("Expected the redirects to be checked but got %v", hits)
+	}
+}
+
+func TestRunRobotsUnavailable(t *testing.T) {
+	var pages int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		atomic.AddInt32(&pages, 1)
+		w.Header().Set("Content-Type", "text/html")
+	}))
+	defer srv.Close()
+	gone := httptest.NewServer(http.NotFoundHandler())
+	defer gone.Close()
+
+	var mu sync.Mutex
+	failed := map[string]error{}
+	c := &crawler.Crawler{
+		OnError: func(u *url.URL, err error) {
+			mu.Lock()
+			failed[u.Host] = err
+			mu.Unlock()
+		},
+	}
+	if err := c.Run(context.Background(), srv.URL, gone.URL); err != nil {
+		t.Fatal(err)
+	}
+	if pages != 0 || failed[srv.Listener.Addr().String()] != crawler.ErrRobots {
+		t.Errorf("Expected a failing robots.txt to disallow everything but got %d pages and %v", pages, failed)
+	}
+	// a missing robots.txt allows everything
+	var se *crawler.StatusError
+	if !errors.As(failed[gone.Listener.Addr().String()], &se) {
+		t.Errorf("Expected a missing robots.txt to allow everything but got %v", failed)
+	}
+}
+
+func TestRunBoundedWorkers(t *testing.T) {
+	const links = 2000
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/" {
+			for i := 0; i < links; i++ {
+				fmt.Fprintf(w, `<a href="/%d">%d</a>`, i, i)
+			}
+		}
+	}))
+	defer srv.Close()
+
+	base := runtime.NumGoroutine()
+	var n, maxGoroutines int32
+	c := &crawler.Crawler{
+		MaxDepth:        1,
+		IgnoreRobots:    true,
+		Concurrency:     4,
+		HostConcurrency: 4,
+		OnPage: func(p *crawler.Page) error {
+			atomic.AddInt32(&n, 1)
+			if g := int32(runtime.NumGoroutine()); g > atomic.LoadInt32(&maxGoroutines) {
+				atomic.StoreInt32(&maxGoroutines, g)
+			}
+			return nil
+		},
+	}
+	if err := c.Run(context.Background(), srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	if n != links+1 {
+		t.Errorf("Expected %d pages but got %d", links+1, n)
+	}
+	// the workers and the connections, not one goroutine per link
+	if maxGoroutines > int32(base)+100 {
+		t.Errorf("Expected a bounded number of goroutines but got %d, from %d", maxGoroutines, base)
+	}
+}
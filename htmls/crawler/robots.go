@@ -0,0 +1,177 @@
+package crawler
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// robots holds the robots.txt rules which apply to the crawler's user agent.
+// A nil *robots allows everything.
+type robots struct {
+	rules []robotsRule
+	delay time.Duration
+}
+
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+// disallowAll is the robots.txt of an unreachable site.
+var disallowAll = &robots{rules: []robotsRule{{allow: false, pattern: "/"}}}
+
+// fetchRobots loads the robots.txt of u's scheme and host, and applies its
+// Crawl-delay to the host. As per RFC 9309, a robots.txt which is missing,
+// with a 4xx status, allows everything, while a server error, a 429 or a
+// network error disallows everything. Up to five redirects are followed.
+func (cr *crawl) fetchRobots(s *slot, u *url.URL) *robots {
+	ru := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/robots.txt"}
+	for redirects := 0; ; redirects++ {
+		resp, body, err := cr.get(s, ru)
+		var se *StatusError
+		switch {
+		case errors.As(err, &se):
+			if se.StatusCode >= 500 || se.StatusCode == http.StatusTooManyRequests {
+				return disallowAll
+			}
+			return nil
+		case err != nil:
+			return disallowAll
+		case isRedirect(resp):
+			loc, err := resp.Location()
+			if err != nil || redirects >= 5 {
+				return nil
+			}
+			ru = loc
+			continue
+		}
+
+		r := parseRobots(body, cr.userAgent())
+		if r != nil && r.delay > 0 {
+			cr.mu.Lock()
+			if r.delay > s.delay {
+				s.delay = r.delay
+			}
+			cr.mu.Unlock()
+		}
+		return r
+	}
+}
+
+// parseRobots returns the rules of the group whose User-agent is the longest
+// match for userAgent, or else of the "*" group.
+func parseRobots(body []byte, userAgent string) *robots {
+	userAgent = strings.ToLower(userAgent)
+	var (
+		best, star *robots
+		bestLen    int
+		group      []*robots // the groups of the current User-agent lines
+		inAgents   bool
+	)
+
+	sc := bufio.NewScanner(bytes.NewReader(body))
+	for sc.Scan() {
+		line := sc.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		i := strings.IndexByte(line, ':')
+		if i < 0 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:i]))
+		value := strings.TrimSpace(line[i+1:])
+
+		if key == "user-agent" {
+			if !inAgents {
+				group, inAgents = nil, true
+			}
+			agent := strings.ToLower(value)
+			switch {
+			case agent == "*" && star == nil:
+				star = &robots{}
+				group = append(group, star)
+			case agent != "*" && strings.Contains(userAgent, agent) && len(agent) > bestLen:
+				best, bestLen = &robots{}, len(agent)
+				group = append(group, best)
+			}
+			continue
+		}
+		inAgents = false
+
+		for _, r := range group {
+			switch key {
+			case "allow", "disallow":
+				if value != "" {
+					r.rules = append(r.rules, robotsRule{allow: key == "allow", pattern: value})
+				}
+			case "crawl-delay":
+				if secs, err := strconv.ParseFloat(value, 64); err == nil && secs > 0 {
+					r.delay = time.Duration(secs * float64(time.Second))
+				}
+			}
+		}
+	}
+
+	if best != nil {
+		return best
+	}
+	return star
+}
+
+// allowed reports whether u may be fetched: the longest matching rule wins,
+// and Allow wins a tie.
+func (r *robots) allowed(u *url.URL) bool {
+	if r == nil {
+		return true
+	}
+	path := u.EscapedPath()
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	allow, longest := true, -1
+	for _, rule := range r.rules {
+		if !matchRobots(rule.pattern, path) {
+			continue
+		}
+		if l := len(rule.pattern); l > longest || l == longest && rule.allow {
+			allow, longest = rule.allow, l
+		}
+	}
+	return allow
+}
+
+// matchRobots matches a path against a robots.txt pattern, where * matches
+// any sequence and a trailing $ anchors the end.
+func matchRobots(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = pattern[:len(pattern)-1]
+	}
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	if len(parts) == 1 {
+		return !anchored || rest == ""
+	}
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(rest, part)
+		if i < 0 {
+			return false
+		}
+		rest = rest[i+len(part):]
+	}
+	last := parts[len(parts)-1]
+	if anchored {
+		return strings.HasSuffix(rest, last)
+	}
+	return strings.Contains(rest, last)
+}
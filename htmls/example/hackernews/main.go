@@ -6,9 +6,19 @@ import (
 
 	"github.com/Akagi201/utils-go/htmls"
 	"golang.org/x/net/html"
-	"golang.org/x/net/html/atom"
 )
 
+// Article is a single front page entry.
+type Article struct {
+	Title string `htmls:"selector=td > a"`
+	URL   string `htmls:"selector=td > a,attr=href"`
+}
+
+// FrontPage describes where to find the articles.
+type FrontPage struct {
+	Articles []Article `htmls:"selector=tr.athing"`
+}
+
 func main() {
 	// request and parse the front page
 	resp, err := http.Get("https://news.ycombinator.com/")
@@ -20,17 +30,12 @@ func main() {
 		panic(err)
 	}
 
-	// define a matcher
-	matcher := func(n *html.Node) bool {
-		// must check for nil values
-		if n.DataAtom == atom.A && n.Parent != nil && n.Parent.Parent != nil {
-			return htmls.Attr(n.Parent.Parent, "class") == "athing"
-		}
-		return false
-	}
 	// grab all articles and print them
-	articles := htmls.FindAll(root, matcher)
-	for i, article := range articles {
-		fmt.Printf("%2d %s (%s)\n", i, htmls.Text(article), htmls.Attr(article, "href"))
+	var page FrontPage
+	if err := htmls.Unmarshal(root, &page); err != nil {
+		panic(err)
+	}
+	for i, article := range page.Articles {
+		fmt.Printf("%2d %s (%s)\n", i, article.Title, article.URL)
 	}
 }
@@ -1,12 +1,13 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"net/http"
-	"sync"
+	"net/url"
+	"os"
 
 	"github.com/Akagi201/utils-go/htmls"
-	"golang.org/x/net/html"
+	"github.com/Akagi201/utils-go/htmls/crawler"
 	"golang.org/x/net/html/atom"
 )
 
@@ -21,78 +22,24 @@ var urls = []string{
 	"http://digg.com",
 }
 
-func respGen(urls ...string) <-chan *http.Response {
-	var wg sync.WaitGroup
-	out := make(chan *http.Response)
-	wg.Add(len(urls))
-	for _, url := range urls {
-		go func(url string) {
-			req, err := http.NewRequest("GET", url, nil)
-			if err != nil {
-				panic(err)
-			}
-			req.Header.Set("user-agent", "testBot("+email+")")
-			resp, err := http.DefaultClient.Do(req)
-			if err != nil {
-				panic(err)
-			}
-			out <- resp
-			wg.Done()
-		}(url)
-	}
-	go func() {
-		wg.Wait()
-		close(out)
-	}()
-	return out
-}
-
-func rootGen(in <-chan *http.Response) <-chan *html.Node {
-	var wg sync.WaitGroup
-	out := make(chan *html.Node)
-	for resp := range in {
-		wg.Add(1)
-		go func(resp *http.Response) {
-			root, err := html.Parse(resp.Body)
-			if err != nil {
-				panic(err)
-			}
-			out <- root
-			wg.Done()
-		}(resp)
-	}
-	go func() {
-		wg.Wait()
-		close(out)
-	}()
-	return out
-}
-
-func titleGen(in <-chan *html.Node) <-chan string {
-	var wg sync.WaitGroup
-	out := make(chan string)
-	for root := range in {
-		wg.Add(1)
-		go func(root *html.Node) {
-			title, ok := htmls.Find(root, htmls.ByTag(atom.Title))
-			if ok {
-				out <- htmls.Text(title)
+func main() {
+	// Fetch the pages concurrently and print the title of each of them.
+	// Raise MaxDepth and add Filters to follow their links as well.
+	c := &crawler.Crawler{
+		UserAgent: "testBot(" + email + ")",
+		Retries:   2,
+		OnPage: func(p *crawler.Page) error {
+			if title, ok := htmls.Find(p.Root, htmls.ByTag(atom.Title)); ok {
+				fmt.Println(htmls.Text(title))
 			}
-			wg.Done()
-		}(root)
+			return nil
+		},
+		OnError: func(u *url.URL, err error) {
+			fmt.Fprintln(os.Stderr, u, err)
+		},
 	}
-	go func() {
-		wg.Wait()
-		close(out)
-	}()
-	return out
-}
-
-func main() {
-	// Set up the pipeline to consume back-to-back output
-	// ending with the final stage to print the title of
-	// each web page in the main go routine.
-	for title := range titleGen(rootGen(respGen(urls...))) {
-		fmt.Println(title)
+	if err := c.Run(context.Background(), urls...); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 }
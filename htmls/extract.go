@@ -0,0 +1,261 @@
+package htmls
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// BaseURL returns the URL relative links in the document are resolved
+// against: the first <base href>, itself resolved against docURL, or docURL.
+// docURL is where the document was fetched from and may be nil.
+func BaseURL(root *html.Node, docURL *url.URL) *url.URL {
+	base, ok := Find(root, And(ByTag(atom.Base), HasAttr("href")))
+	if !ok {
+		return docURL
+	}
+	u, err := url.Parse(strings.TrimSpace(Attr(base, "href")))
+	if err != nil {
+		return docURL
+	}
+	if docURL != nil {
+		u = docURL.ResolveReference(u)
+	}
+	return u
+}
+
+// resolve parses ref and resolves it against base, which may be nil.
+func resolve(base *url.URL, ref string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(ref))
+	if err != nil {
+		return nil, err
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	return u, nil
+}
+
+// Link is a hyperlink found by Links.
+type Link struct {
+	// URL is the absolute URL when the document URL or a <base href> is known.
+	URL  *url.URL
+	Text string
+	Rel  []string
+	Node *html.Node
+}
+
+// Links returns the a and area elements with an href, in document order,
+// resolved against the document's BaseURL. Links which fail to parse and
+// javascript: links are skipped.
+//
+//	u, _ := url.Parse("https://example.com/blog/")
+//	for _, l := range htmls.Links(root, u) {
+//	    fmt.Println(l.URL, l.Text)
+//	}
+func Links(root *html.Node, docURL *url.URL) []Link {
+	base := BaseURL(root, docURL)
+	var links []Link
+	for _, n := range FindAllNested(root, And(Or(ByTag(atom.A), ByTag(atom.Area)), HasAttr("href"))) {
+		u, err := resolve(base, Attr(n, "href"))
+		if err != nil || strings.EqualFold(u.Scheme, "javascript") {
+			continue
+		}
+		links = append(links, Link{
+			URL:  u,
+			Text: Text(n),
+			Rel:  strings.Fields(strings.ToLower(Attr(n, "rel"))),
+			Node: n,
+		})
+	}
+	return links
+}
+
+// Metadata holds the document metadata found by ExtractMetadata.
+type Metadata struct {
+	Title       string
+	Description string
+	// Canonical is the resolved <link rel="canonical"> URL, if any.
+	Canonical *url.URL
+	// Meta maps the name, property or http-equiv of each <meta> to its content.
+	// The first occurrence of a key wins.
+	Meta map[string]string
+	// OpenGraph maps og: properties to their values, e.g. "og:image". A property may repeat.
+	OpenGraph map[string][]string
+	// Twitter maps twitter: card properties to their values, e.g. "twitter:card".
+	Twitter map[string]string
+	// JSONLD holds the valid application/ld+json scripts.
+	JSONLD []json.RawMessage
+}
+
+// ExtractMetadata reads the <title>, <meta>, OpenGraph, Twitter card,
+// JSON-LD and canonical link of a document.
+func ExtractMetadata(root *html.Node, docURL *url.URL) *Metadata {
+	md := &Metadata{
+		Meta:      map[string]string{},
+		OpenGraph: map[string][]string{},
+		Twitter:   map[string]string{},
+	}
+
+	if title, ok := Find(root, ByTag(atom.Title)); ok {
+		md.Title = Text(title)
+	}
+
+	for _, n := range FindAllNested(root, ByTag(atom.Meta)) {
+		key := Attr(n, "property")
+		if key == "" {
+			key = Attr(n, "name")
+		}
+		if key == "" {
+			key = Attr(n, "http-equiv")
+		}
+		if key == "" {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		content := strings.TrimSpace(Attr(n, "content"))
+
+		if _, ok := md.Meta[key]; !ok {
+			md.Meta[key] = content
+		}
+		switch {
+		case strings.HasPrefix(key, "og:"):
+			md.OpenGraph[key] = append(md.OpenGraph[key], content)
+		case strings.HasPrefix(key, "twitter:"):
+			if _, ok := md.Twitter[key]; !ok {
+				md.Twitter[key] = content
+			}
+		}
+	}
+	md.Description = md.Meta["description"]
+
+	base := BaseURL(root, docURL)
+	for _, n := range FindAllNested(root, And(ByTag(atom.Link), HasAttr("href"))) {
+		for _, rel := range strings.Fields(strings.ToLower(Attr(n, "rel"))) {
+			if rel == "canonical" && md.Canonical == nil {
+				md.Canonical, _ = resolve(base, Attr(n, "href"))
+			}
+		}
+	}
+
+	ldJSON := And(ByTag(atom.Script), func(n *html.Node) bool {
+		return strings.EqualFold(strings.TrimSpace(Attr(n, "type")), "application/ld+json")
+	})
+	for _, n := range FindAllNested(root, ldJSON) {
+		raw := strings.TrimSpace(rawText(n))
+		if json.Valid([]byte(raw)) {
+			md.JSONLD = append(md.JSONLD, json.RawMessage(raw))
+		}
+	}
+	return md
+}
+
+// Form is an HTML form as found by Forms.
+type Form struct {
+	// Action is the resolved URL the form submits to.
+	Action *url.URL
+	// Method is GET or POST.
+	Method  string
+	Enctype string
+	// Values holds what the browser would submit without user interaction.
+	Values url.Values
+	Node   *html.Node
+}
+
+// Forms returns every form in the document, see ParseForm.
+func Forms(root *html.Node, docURL *url.URL) []Form {
+	var forms []Form
+	for _, n := range FindAllNested(root, ByTag(atom.Form)) {
+		forms = append(forms, ParseForm(n, docURL))
+	}
+	return forms
+}
+
+// ParseForm reads the action, method and the values a browser would submit
+// for a form: named, enabled fields, checked checkboxes and radio buttons and
+// selected options. Buttons, file inputs and fields belonging to another
+// form through their form attribute are left out; fields outside of the form
+// which belong to it are included.
+func ParseForm(form *html.Node, docURL *url.URL) Form {
+	base := BaseURL(rootOf(form), docURL)
+	f := Form{
+		Method:  strings.ToUpper(strings.TrimSpace(Attr(form, "method"))),
+		Enctype: strings.TrimSpace(Attr(form, "enctype")),
+		Values:  url.Values{},
+		Node:    form,
+	}
+	if f.Method != "POST" {
+		f.Method = "GET"
+	}
+	if f.Enctype == "" {
+		f.Enctype = "application/x-www-form-urlencoded"
+	}
+	f.Action, _ = resolve(base, Attr(form, "action"))
+
+	isField := Or(ByTag(atom.Input), ByTag(atom.Select), ByTag(atom.Textarea))
+	id := Attr(form, "id")
+	owned := func(n *html.Node) bool {
+		if !isField(n) {
+			return false
+		}
+		if owner, ok := attr(n, "form"); ok {
+			return id != "" && owner == id
+		}
+		p, ok := FindParent(n, ByTag(atom.Form))
+		return ok && p == form
+	}
+
+	for _, n := range FindAllNested(rootOf(form), owned) {
+		name := Attr(n, "name")
+		if _, disabled := attr(n, "disabled"); name == "" || disabled {
+			continue
+		}
+		switch n.DataAtom {
+		case atom.Input:
+			_, checked := attr(n, "checked")
+			value, hasValue := attr(n, "value")
+			switch strings.ToLower(Attr(n, "type")) {
+			case "submit", "button", "image", "reset", "file":
+			case "checkbox", "radio":
+				if !checked {
+					continue
+				}
+				if !hasValue {
+					value = "on"
+				}
+				f.Values.Add(name, value)
+			default:
+				f.Values.Add(name, value)
+			}
+		case atom.Textarea:
+			// the parser already drops the newline following <textarea>
+			f.Values.Add(name, rawText(n))
+		case atom.Select:
+			_, multiple := attr(n, "multiple")
+			options := FindAllNested(n, ByTag(atom.Option))
+			var selected []*html.Node
+			for _, o := range options {
+				if _, ok := attr(o, "selected"); ok {
+					selected = append(selected, o)
+				}
+			}
+			if len(selected) == 0 && !multiple && len(options) > 0 {
+				selected = options[:1]
+			}
+			if !multiple && len(selected) > 1 {
+				selected = selected[len(selected)-1:]
+			}
+			for _, o := range selected {
+				value, ok := attr(o, "value")
+				if !ok {
+					value = strings.Join(strings.Fields(rawText(o)), " ")
+				}
+				f.Values.Add(name, value)
+			}
+		}
+	}
+	return f
+}
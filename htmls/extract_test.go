@@ -0,0 +1,150 @@
+package htmls_test
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Akagi201/utils-go/htmls"
+	"golang.org/x/net/html"
+)
+
+const testExtractHTML = `
+<html>
+  <head>
+    <title> Example Page </title>
+    <base href="/docs/">
+    <meta name="description" content="An example">
+    <meta property="og:title" content="OG Title">
+    <meta property="og:image" content="https://example.com/1.png">
+    <meta property="og:image" content="https://example.com/2.png">
+    <meta name="twitter:card" content="summary">
+    <meta http-equiv="refresh" content="30">
+    <link rel="canonical" href="page.html">
+    <script type="application/ld+json">{"@type": "Article", "name": "x"}</script>
+    <script type="application/ld+json">{broken</script>
+  </head>
+  <body>
+    <a href="intro.html" rel="Next">Intro</a>
+    <a href="https://other.example.com/">Other</a>
+    <a href="javascript:void(0)">JS</a>
+    <a name="anchor">no href</a>
+    <map><area href="/map" alt="map"></map>
+
+    <form id="search" action="search" method="post">
+      <input type="hidden" name="token" value="abc">
+      <input type="text" name="q" value="golang">
+      <input type="checkbox" name="opt" value="a" checked>
+      <input type="checkbox" name="opt" value="b">
+      <input type="checkbox" name="flag" checked>
+      <input type="radio" name="r" value="1">
+      <input type="radio" name="r" value="2" checked>
+      <input type="text" name="off" value="x" disabled>
+      <input type="submit" name="go" value="Go">
+      <input type="text" value="unnamed">
+      <select name="lang"><option>en</option><option value="fr">French</option></select>
+      <select name="multi" multiple><option selected>a</option><option selected value="b">B</option><option>c</option></select>
+      <textarea name="body">
+hello</textarea>
+      <input type="text" name="other" form="elsewhere" value="no">
+    </form>
+    <input type="text" name="outside" form="search" value="yes">
+    <form><input name="x" value="1"></form>
+  </body>
+</html>
+`
+
+func TestLinks(t *testing.T) {
+	root, _ := html.Parse(strings.NewReader(testExtractHTML))
+	doc, _ := url.Parse("https://example.com/a/b.html")
+
+	links := htmls.Links(root, doc)
+	var got []string
+	for _, l := range links {
+		got = append(got, l.URL.String()+" "+l.Text)
+	}
+	want := []string{
+		"https://example.com/docs/intro.html Intro",
+		"https://other.example.com/ Other",
+		"https://example.com/map ",
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Unexpected links %q", got)
+	}
+	if len(links[0].Rel) != 1 || links[0].Rel[0] != "next" {
+		t.Errorf("Unexpected rel %q", links[0].Rel)
+	}
+
+	if base := htmls.BaseURL(root, nil); base.String() != "/docs/" {
+		t.Errorf("Unexpected base without document URL %q", base)
+	}
+}
+
+func TestExtractMetadata(t *testing.T) {
+	root, _ := html.Parse(strings.NewReader(testExtractHTML))
+	doc, _ := url.Parse("https://example.com/a/b.html")
+
+	md := htmls.ExtractMetadata(root, doc)
+	if md.Title != "Example Page" || md.Description != "An example" {
+		t.Errorf("Unexpected title or description: %q %q", md.Title, md.Description)
+	}
+	if md.Canonical == nil || md.Canonical.String() != "https://example.com/docs/page.html" {
+		t.Errorf("Unexpected canonical %v", md.Canonical)
+	}
+	if md.Meta["refresh"] != "30" || md.Meta["og:title"] != "OG Title" {
+		t.Errorf("Unexpected meta %v", md.Meta)
+	}
+	if imgs := md.OpenGraph["og:image"]; len(imgs) != 2 || imgs[1] != "https://example.com/2.png" {
+		t.Errorf("Unexpected og:image %q", imgs)
+	}
+	if md.Twitter["twitter:card"] != "summary" {
+		t.Errorf("Unexpected twitter card %v", md.Twitter)
+	}
+	if len(md.JSONLD) != 1 {
+		t.Fatalf("Expected 1 JSON-LD object but found %d", len(md.JSONLD))
+	}
+	var ld map[string]string
+	if err := json.Unmarshal(md.JSONLD[0], &ld); err != nil || ld["@type"] != "Article" {
+		t.Errorf("Unexpected JSON-LD %s (%v)", md.JSONLD[0], err)
+	}
+}
+
+func TestForms(t *testing.T) {
+	root, _ := html.Parse(strings.NewReader(testExtractHTML))
+	doc, _ := url.Parse("https://example.com/a/b.html")
+
+	forms := htmls.Forms(root, doc)
+	if len(forms) != 2 {
+		t.Fatalf("Expected 2 forms but found %d", len(forms))
+	}
+
+	f := forms[0]
+	if f.Method != "POST" || f.Action.String() != "https://example.com/docs/search" {
+		t.Errorf("Unexpected method or action: %s %s", f.Method, f.Action)
+	}
+	want := url.Values{
+		"token":   {"abc"},
+		"q":       {"golang"},
+		"opt":     {"a"},
+		"flag":    {"on"},
+		"r":       {"2"},
+		"lang":    {"en"},
+		"multi":   {"a", "b"},
+		"body":    {"hello"},
+		"outside": {"yes"},
+	}
+	if f.Values.Encode() != want.Encode() {
+		t.Errorf("Unexpected values %s, want %s", f.Values.Encode(), want.Encode())
+	}
+
+	if forms[1].Method != "GET" || forms[1].Action.String() != "https://example.com/docs/" || forms[1].Values.Get("x") != "1" {
+		t.Errorf("Unexpected second form %+v", forms[1])
+	}
+
+	// only the first newline after <textarea> is dropped, like browsers do
+	root, _ = html.Parse(strings.NewReader("<form><textarea name=t>\n\nx</textarea></form>"))
+	if forms := htmls.Forms(root, doc); len(forms) != 1 || forms[0].Values.Get("t") != "\nx" {
+		t.Errorf("Unexpected textarea value in %+v", forms)
+	}
+}
@@ -2,6 +2,7 @@
 package htmls
 
 import (
+	"regexp"
 	"strings"
 
 	"golang.org/x/net/html"
@@ -133,10 +134,98 @@ func ByClass(class string) MatchFunc {
 	}
 }
 
+// And returns a MatchFunc which matches nodes matched by all of the provided MatchFuncs.
+//
+//     nofollow := htmls.And(htmls.ByTag(atom.A), htmls.ByAttr("rel", "nofollow"))
+func And(mfs ...MatchFunc) MatchFunc {
+	return func(node *html.Node) bool {
+		for _, mf := range mfs {
+			if !mf(node) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or returns a MatchFunc which matches nodes matched by any of the provided MatchFuncs.
+func Or(mfs ...MatchFunc) MatchFunc {
+	return func(node *html.Node) bool {
+		for _, mf := range mfs {
+			if mf(node) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not returns a MatchFunc which matches nodes not matched by the provided MatchFunc.
+func Not(mf MatchFunc) MatchFunc {
+	return func(node *html.Node) bool { return !mf(node) }
+}
+
+// HasAttr returns a MatchFunc which matches all nodes having the provided attribute.
+func HasAttr(key string) MatchFunc {
+	return func(node *html.Node) bool {
+		_, ok := attr(node, key)
+		return ok
+	}
+}
+
+// ByAttr returns a MatchFunc which matches all nodes whose attribute equals the provided value.
+func ByAttr(key, value string) MatchFunc {
+	return func(node *html.Node) bool {
+		v, ok := attr(node, key)
+		return ok && v == value
+	}
+}
+
+// ByAttrPrefix returns a MatchFunc which matches all nodes whose attribute starts with the provided prefix.
+func ByAttrPrefix(key, prefix string) MatchFunc {
+	return func(node *html.Node) bool {
+		v, ok := attr(node, key)
+		return ok && strings.HasPrefix(v, prefix)
+	}
+}
+
+// ByAttrRegexp returns a MatchFunc which matches all nodes whose attribute matches the provided regexp.
+func ByAttrRegexp(key string, re *regexp.Regexp) MatchFunc {
+	return func(node *html.Node) bool {
+		v, ok := attr(node, key)
+		return ok && re.MatchString(v)
+	}
+}
+
+// ByText returns a MatchFunc which matches all nodes whose Text matches the provided regexp.
+// Combine it with ByTag to avoid matching every ancestor of the text as well.
+func ByText(re *regexp.Regexp) MatchFunc {
+	return func(node *html.Node) bool { return re.MatchString(Text(node)) }
+}
+
+// ByDepth returns a MatchFunc which matches all nodes with the provided number
+// of ancestors, the document node being at depth 0.
+func ByDepth(depth int) MatchFunc {
+	return func(node *html.Node) bool {
+		d := 0
+		for p := node.Parent; p != nil; p = p.Parent {
+			d++
+			if d > depth {
+				return false
+			}
+		}
+		return d == depth
+	}
+}
+
 // findAllInternal encapsulates the node tree traversal
 func findAllInternal(node *html.Node, mf MatchFunc, searchNested bool) []*html.Node {
-	matched := []*html.Node{}
+	return appendMatches([]*html.Node{}, node, mf, searchNested)
+}
 
+// appendMatches appends the matching nodes to matched, so no intermediate
+// slices are allocated for each level of the tree.
+func appendMatches(matched []*html.Node, node *html.Node, mf MatchFunc, searchNested bool) []*html.Node {
 	if mf(node) {
 		matched = append(matched, node)
 
@@ -146,10 +235,7 @@ func findAllInternal(node *html.Node, mf MatchFunc, searchNested bool) []*html.N
 	}
 
 	for c := node.FirstChild; c != nil; c = c.NextSibling {
-		found := findAllInternal(c, mf, searchNested)
-		if len(found) > 0 {
-			matched = append(matched, found...)
-		}
+		matched = appendMatches(matched, c, mf, searchNested)
 	}
 	return matched
 }
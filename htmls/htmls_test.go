@@ -1,6 +1,7 @@
 package htmls_test
 
 import (
+	"regexp"
 	"strings"
 	"testing"
 
@@ -125,3 +126,42 @@ func TestFindPrevSiblingDoesntReturnChildren(t *testing.T) {
 		}
 	}
 }
+
+const testMatchHTML = `
+<html>
+  <body>
+    <a href="http://a.example.com" rel="nofollow">first</a>
+    <a href="/b" rel="author">second</a>
+    <a href="https://c.example.com">third</a>
+    <div><a id="d">fourth</a></div>
+  </body>
+</html>
+`
+
+func TestMatchFuncCombinators(t *testing.T) {
+	node, _ := html.Parse(strings.NewReader(testMatchHTML))
+
+	cases := []struct {
+		name string
+		mf   htmls.MatchFunc
+		want string
+	}{
+		{"And", htmls.And(htmls.ByTag(atom.A), htmls.ByAttr("rel", "nofollow")), "first"},
+		{"Or", htmls.Or(htmls.ByAttr("rel", "author"), htmls.ByID("d")), "second fourth"},
+		{"Not", htmls.And(htmls.ByTag(atom.A), htmls.Not(htmls.HasAttr("rel"))), "third fourth"},
+		{"HasAttr", htmls.HasAttr("href"), "first second third"},
+		{"ByAttrPrefix", htmls.ByAttrPrefix("href", "http"), "first third"},
+		{"ByAttrRegexp", htmls.ByAttrRegexp("href", regexp.MustCompile(`^https://`)), "third"},
+		{"ByText", htmls.And(htmls.ByTag(atom.A), htmls.ByText(regexp.MustCompile(`^f`))), "first fourth"},
+		{"ByDepth", htmls.And(htmls.ByTag(atom.A), htmls.ByDepth(3)), "first second third"},
+	}
+	for _, c := range cases {
+		var texts []string
+		for _, n := range htmls.FindAllNested(node, c.mf) {
+			texts = append(texts, htmls.Text(n))
+		}
+		if got := strings.Join(texts, " "); got != c.want {
+			t.Errorf("%s matched %q, want %q", c.name, got, c.want)
+		}
+	}
+}
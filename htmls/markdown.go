@@ -0,0 +1,506 @@
+package htmls
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// PlainTextOptions configures ToPlainText.
+type PlainTextOptions struct {
+	// LinkURLs appends the URL to the text of each link, like "text <url>".
+	LinkURLs bool
+	// ImageAlt renders images as their alt text in brackets, like "[alt]".
+	ImageAlt bool
+}
+
+// ToMarkdown converts node and its descendants to Markdown, keeping
+// headings, paragraphs, lists, block quotes, code, links, images and tables.
+// Scripts, styles and the document head are skipped.
+func ToMarkdown(node *html.Node) string {
+	c := &converter{markdown: true}
+	c.convert(node)
+	return c.String()
+}
+
+// ToPlainText converts node and its descendants to plain text. Unlike Text,
+// it keeps the block structure: paragraphs are separated by blank lines,
+// list items get bullets and tables are laid out in aligned columns.
+func ToPlainText(node *html.Node, opts PlainTextOptions) string {
+	c := &converter{opts: opts}
+	c.convert(node)
+	return c.String()
+}
+
+// converter renders the tree line by line, collapsing whitespace and
+// inserting the line breaks requested by block elements lazily, so blocks
+// never produce more than one blank line between them.
+type converter struct {
+	markdown bool
+	opts     PlainTextOptions
+
+	sb strings.Builder
+	// prefixes are written at the start of each line, for block quotes and list items.
+	prefixes []string
+	// marker replaces the last prefix on the next line, e.g. "- " for a list item.
+	marker string
+	// pendingBreak is the number of newlines to write before the next text.
+	pendingBreak int
+	// fresh is set at the start of a container, where blocks need no separation.
+	fresh        bool
+	atLineStart  bool
+	spacePending bool
+	// pendingMarks are opening emphasis marks, written with the next text so
+	// that emphasis rendering to nothing leaves no marks. They mustn't be
+	// followed by a space.
+	pendingMarks string
+	// cell is set for the text of a table cell, which never starts a line.
+	cell   bool
+	inList int
+}
+
+func (c *converter) String() string {
+	return strings.TrimRightFunc(c.sb.String(), unicode.IsSpace)
+}
+
+func (c *converter) block() {
+	if !c.fresh && c.sb.Len() > 0 {
+		c.pendingBreak = 2
+	}
+}
+
+func (c *converter) lineBreak() {
+	if !c.fresh && c.sb.Len() > 0 && c.pendingBreak == 0 {
+		c.pendingBreak = 1
+	}
+}
+
+// breakLines writes the pending newlines, blank lines getting the current prefixes.
+func (c *converter) breakLines() {
+	for i := 0; i < c.pendingBreak; i++ {
+		if i > 0 {
+			c.sb.WriteString(strings.TrimRight(strings.Join(c.prefixes, ""), " "))
+		}
+		c.sb.WriteByte('\n')
+		c.atLineStart = true
+		c.spacePending = false
+	}
+	c.pendingBreak = 0
+}
+
+func (c *converter) flush() {
+	c.breakLines()
+	if c.atLineStart || c.sb.Len() == 0 {
+		if c.marker != "" && len(c.prefixes) > 0 {
+			c.sb.WriteString(strings.Join(c.prefixes[:len(c.prefixes)-1], ""))
+			c.sb.WriteString(c.marker)
+			c.marker = ""
+		} else {
+			c.sb.WriteString(strings.Join(c.prefixes, ""))
+		}
+		c.atLineStart = false
+		c.spacePending = false
+	}
+	if c.spacePending {
+		c.sb.WriteByte(' ')
+		c.spacePending = false
+	}
+	c.sb.WriteString(c.pendingMarks)
+	c.pendingMarks = ""
+	c.fresh = false
+}
+
+// writeText writes text with collapsed whitespace.
+func (c *converter) writeText(s string) {
+	escapeAt := -1
+	for i, r := range s {
+		if unicode.IsSpace(r) {
+			if !c.atLineStart && c.pendingMarks == "" && c.pendingBreak == 0 && c.sb.Len() > 0 {
+				c.spacePending = true
+			}
+			continue
+		}
+		lineStart := c.atLineStart || c.pendingBreak > 0 || c.sb.Len() == 0
+		c.flush()
+		if c.markdown {
+			if lineStart && !c.cell {
+				// text which would start a heading, a block quote or a list
+				if strings.ContainsRune("#>-+=", r) {
+					c.sb.WriteByte('\\')
+				} else if j := skipDigits(s, i); j > i && j < len(s) && (s[j] == '.' || s[j] == ')') &&
+					(j+1 == len(s) || unicode.IsSpace(rune(s[j+1]))) {
+					escapeAt = j
+				}
+			}
+			if i == escapeAt || strings.ContainsRune("\\`*_[]", r) {
+				c.sb.WriteByte('\\')
+			}
+		}
+		c.sb.WriteRune(r)
+	}
+}
+
+// skipDigits returns the index of the first non-digit in s from i.
+func skipDigits(s string, i int) int {
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return i
+}
+
+// writeRaw writes s as is, keeping its line breaks.
+func (c *converter) writeRaw(s string) {
+	for i, line := range strings.Split(s, "\n") {
+		if i > 0 {
+			c.pendingBreak = 1
+		}
+		c.flush()
+		c.sb.WriteString(line)
+	}
+}
+
+func (c *converter) push(prefix, marker string) {
+	c.breakLines()
+	c.prefixes = append(c.prefixes, prefix)
+	c.marker = marker
+	c.fresh = true
+}
+
+func (c *converter) pop() {
+	c.prefixes = c.prefixes[:len(c.prefixes)-1]
+	c.marker = ""
+	c.fresh = false
+}
+
+func (c *converter) children(n *html.Node) {
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		c.convert(ch)
+	}
+}
+
+func (c *converter) convert(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		c.writeText(n.Data)
+		return
+	case html.DocumentNode:
+		c.children(n)
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.Head, atom.Script, atom.Style, atom.Noscript, atom.Template, atom.Title:
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		c.block()
+		if c.markdown {
+			c.flush()
+			level := int(n.Data[1] - '0')
+			c.sb.WriteString(strings.Repeat("#", level) + " ")
+		}
+		c.children(n)
+		c.block()
+	case atom.P, atom.Div, atom.Section, atom.Article, atom.Header, atom.Footer, atom.Main,
+		atom.Nav, atom.Aside, atom.Figure, atom.Figcaption, atom.Address, atom.Form, atom.Fieldset,
+		atom.Details, atom.Summary, atom.Center:
+		c.block()
+		c.children(n)
+		c.block()
+	case atom.Br:
+		if c.markdown && !c.atLineStart && c.sb.Len() > 0 {
+			c.sb.WriteByte('\\')
+		}
+		c.pendingBreak = 1
+		c.fresh = false
+	case atom.Hr:
+		c.block()
+		if c.markdown {
+			c.writeRaw("---")
+		}
+		c.block()
+	case atom.Ul, atom.Ol:
+		if c.inList > 0 {
+			c.lineBreak()
+		} else {
+			c.block()
+		}
+		c.list(n)
+		c.block()
+	case atom.Li:
+		// a list item outside of a list
+		c.lineBreak()
+		c.push("  ", "- ")
+		c.children(n)
+		c.pop()
+	case atom.Dl:
+		c.block()
+		for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+			if ch.DataAtom == atom.Dd {
+				c.lineBreak()
+				c.push("    ", "")
+				c.children(ch)
+				c.pop()
+			} else {
+				c.lineBreak()
+				c.convert(ch)
+			}
+		}
+		c.block()
+	case atom.Blockquote:
+		c.block()
+		if c.markdown {
+			c.push("> ", "")
+		} else {
+			c.push("    ", "")
+		}
+		c.children(n)
+		c.pop()
+		c.block()
+	case atom.Pre:
+		c.block()
+		code := strings.TrimSuffix(rawText(n), "\n")
+		if c.markdown {
+			fence := codeFence(code, 3)
+			c.writeRaw(fence + "\n" + code + "\n" + fence)
+		} else {
+			c.writeRaw(code)
+		}
+		c.block()
+	case atom.Code, atom.Kbd, atom.Samp, atom.Tt:
+		code := strings.Join(strings.Fields(rawText(n)), " ")
+		if code == "" {
+			return
+		}
+		c.flush()
+		if c.markdown {
+			fence := codeFence(code, 1)
+			if strings.HasPrefix(code, "`") || strings.HasSuffix(code, "`") {
+				// one space is stripped from each side
+				code = " " + code + " "
+			}
+			c.sb.WriteString(fence + code + fence)
+		} else {
+			c.sb.WriteString(code)
+		}
+	case atom.Strong, atom.B:
+		c.emphasis(n, "**")
+	case atom.Em, atom.I:
+		c.emphasis(n, "_")
+	case atom.A:
+		c.link(n)
+	case atom.Img:
+		alt := strings.Join(strings.Fields(Attr(n, "alt")), " ")
+		if c.markdown {
+			c.flush()
+			c.sb.WriteString("![" + escapeMarkdown(alt) + "](" + markdownURL(Attr(n, "src")) + ")")
+		} else if c.opts.ImageAlt && alt != "" {
+			c.flush()
+			c.sb.WriteString("[" + alt + "]")
+		}
+	case atom.Table:
+		c.block()
+		c.table(n)
+		c.block()
+	default:
+		c.children(n)
+	}
+}
+
+// emphasis puts the marks around the text of n, leaving the surrounding
+// whitespace outside as Markdown requires: "<b>Note: </b>x" is "**Note:** x".
+func (c *converter) emphasis(n *html.Node, mark string) {
+	if !c.markdown {
+		c.children(n)
+		return
+	}
+	if r, _ := utf8.DecodeRuneInString(rawText(n)); unicode.IsSpace(r) {
+		c.writeText(" ")
+	}
+	c.pendingMarks += mark
+	c.children(n)
+	if strings.HasSuffix(c.pendingMarks, mark) {
+		// nothing was rendered
+		c.pendingMarks = strings.TrimSuffix(c.pendingMarks, mark)
+		return
+	}
+	// a trailing space is still pending, and written after the mark
+	c.sb.WriteString(mark)
+}
+
+func (c *converter) link(n *html.Node) {
+	href, hasHref := attr(n, "href")
+	if !hasHref || href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+		c.children(n)
+		return
+	}
+	switch {
+	case c.markdown:
+		c.flush()
+		c.sb.WriteByte('[')
+		start := c.sb.Len()
+		c.children(n)
+		if c.sb.Len() == start {
+			c.sb.WriteString(escapeMarkdown(href))
+		}
+		c.sb.WriteString("](" + markdownURL(href) + ")")
+	case c.opts.LinkURLs:
+		c.children(n)
+		c.flush()
+		c.sb.WriteString(" <" + href + ">")
+	default:
+		c.children(n)
+	}
+}
+
+func (c *converter) list(n *html.Node) {
+	i := 1
+	if start, err := strconv.Atoi(Attr(n, "start")); err == nil {
+		i = start
+	}
+	c.inList++
+	for li := n.FirstChild; li != nil; li = li.NextSibling {
+		if li.Type != html.ElementNode {
+			continue
+		}
+		if li.DataAtom != atom.Li {
+			c.convert(li)
+			continue
+		}
+		marker := "- "
+		if n.DataAtom == atom.Ol {
+			marker = strconv.Itoa(i) + ". "
+			i++
+		}
+		c.lineBreak()
+		c.push(strings.Repeat(" ", len(marker)), marker)
+		c.children(li)
+		c.pop()
+	}
+	c.inList--
+}
+
+func (c *converter) table(n *html.Node) {
+	var rows [][]string
+	header := false
+	var addRows func(*html.Node)
+	addRows = func(p *html.Node) {
+		for ch := p.FirstChild; ch != nil; ch = ch.NextSibling {
+			switch ch.DataAtom {
+			case atom.Thead, atom.Tbody, atom.Tfoot:
+				addRows(ch)
+			case atom.Tr:
+				var row []string
+				allTh := true
+				for cell := ch.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.DataAtom != atom.Td && cell.DataAtom != atom.Th {
+						continue
+					}
+					allTh = allTh && cell.DataAtom == atom.Th
+					sub := &converter{markdown: c.markdown, opts: c.opts, cell: true}
+					sub.children(cell)
+					row = append(row, strings.Join(strings.Fields(sub.String()), " "))
+				}
+				if len(rows) == 0 && (allTh && len(row) > 0 || p.DataAtom == atom.Thead) {
+					header = true
+				}
+				rows = append(rows, row)
+			}
+		}
+	}
+	addRows(n)
+	if len(rows) == 0 {
+		return
+	}
+
+	cols := 0
+	for _, row := range rows {
+		if len(row) > cols {
+			cols = len(row)
+		}
+	}
+	for i := range rows {
+		for len(rows[i]) < cols {
+			rows[i] = append(rows[i], "")
+		}
+	}
+
+	var lines []string
+	if c.markdown {
+		if !header {
+			rows = append([][]string{make([]string, cols)}, rows...)
+		}
+		for i, row := range rows {
+			for j := range row {
+				row[j] = strings.ReplaceAll(row[j], "|", `\|`)
+			}
+			lines = append(lines, "| "+strings.Join(row, " | ")+" |")
+			if i == 0 {
+				lines = append(lines, "|"+strings.Repeat(" --- |", cols))
+			}
+		}
+	} else {
+		widths := make([]int, cols)
+		for _, row := range rows {
+			for j, cell := range row {
+				if w := utf8.RuneCountInString(cell); w > widths[j] {
+					widths[j] = w
+				}
+			}
+		}
+		for _, row := range rows {
+			var sb strings.Builder
+			for j, cell := range row {
+				sb.WriteString(cell)
+				if j < cols-1 {
+					sb.WriteString(strings.Repeat(" ", widths[j]-utf8.RuneCountInString(cell)+2))
+				}
+			}
+			lines = append(lines, strings.TrimRight(sb.String(), " "))
+		}
+	}
+	c.writeRaw(strings.Join(lines, "\n"))
+}
+
+// rawText returns the text of all descendant text nodes, untrimmed.
+func rawText(n *html.Node) string {
+	return TextJoin(n, func(s []string) string { return strings.Join(s, "") })
+}
+
+// codeFence returns a run of backticks longer than any in code, and at least
+// min long.
+func codeFence(code string, min int) string {
+	longest, run := 0, 0
+	for i := 0; i < len(code); i++ {
+		if code[i] != '`' {
+			run = 0
+			continue
+		}
+		if run++; run > longest {
+			longest = run
+		}
+	}
+	if longest < min {
+		return strings.Repeat("`", min)
+	}
+	return strings.Repeat("`", longest+1)
+}
+
+// markdownURL writes a link destination, in angle brackets if it has
+// spaces, parentheses or other characters ending or escaping it.
+func markdownURL(s string) string {
+	if !strings.ContainsAny(s, " ()<>\\\n") {
+		return s
+	}
+	s = strings.NewReplacer("\n", "%0A", `\`, `\\`, "<", `\<`, ">", `\>`).Replace(s)
+	return "<" + s + ">"
+}
+
+func escapeMarkdown(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`).Replace(s)
+}
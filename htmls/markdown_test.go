@@ -0,0 +1,150 @@
+package htmls_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Akagi201/utils-go/htmls"
+	"golang.org/x/net/html"
+)
+
+const testMarkdownHTML = `
+<html>
+  <head><title>ignored</title><style>p { color: red }</style></head>
+  <body>
+    <h1>The   Title</h1>
+    <p>Some <b>bold</b> and <em>emphasized</em> text with a
+       <a href="https://example.com/x">link</a> and <code>a_b()</code>.</p>
+    <p>Line one<br>line two</p>
+    <ul>
+      <li>first</li>
+      <li>second
+        <ol start="3"><li>three</li><li>four</li></ol>
+      </li>
+    </ul>
+    <blockquote><p>quoted</p><p>twice</p></blockquote>
+    <pre>func main() {
+	fmt.Println("hi")
+}
+</pre>
+    <table>
+      <tr><th>Name</th><th>Price</th></tr>
+      <tr><td>Apple</td><td>1.20</td></tr>
+      <tr><td>Watermelon</td><td>3</td></tr>
+    </table>
+    <img src="/logo.png" alt="Logo">
+    <script>ignored()</script>
+  </body>
+</html>
+`
+
+func TestToMarkdown(t *testing.T) {
+	root, _ := html.Parse(strings.NewReader(testMarkdownHTML))
+
+	want := "# The Title\n" +
+		"\n" +
+		"Some **bold** and _emphasized_ text with a [link](https://example.com/x) and `a_b()`.\n" +
+		"\n" +
+		"Line one\\\n" +
+		"line two\n" +
+		"\n" +
+		"- first\n" +
+		"- second\n" +
+		"  3. three\n" +
+		"  4. four\n" +
+		"\n" +
+		"> quoted\n" +
+		">\n" +
+		"> twice\n" +
+		"\n" +
+		"```\n" +
+		"func main() {\n" +
+		"\tfmt.Println(\"hi\")\n" +
+		"}\n" +
+		"```\n" +
+		"\n" +
+		"| Name | Price |\n" +
+		"| --- | --- |\n" +
+		"| Apple | 1.20 |\n" +
+		"| Watermelon | 3 |\n" +
+		"\n" +
+		"![Logo](/logo.png)"
+	if got := htmls.ToMarkdown(root); got != want {
+		t.Errorf("Unexpected markdown:\n%s\n\nwant:\n%s", got, want)
+	}
+}
+
+func TestToPlainText(t *testing.T) {
+	root, _ := html.Parse(strings.NewReader(testMarkdownHTML))
+
+	want := "The Title\n" +
+		"\n" +
+		"Some bold and emphasized text with a link <https://example.com/x> and a_b().\n" +
+		"\n" +
+		"Line one\n" +
+		"line two\n" +
+		"\n" +
+		"- first\n" +
+		"- second\n" +
+		"  3. three\n" +
+		"  4. four\n" +
+		"\n" +
+		"    quoted\n" +
+		"\n" +
+		"    twice\n" +
+		"\n" +
+		"func main() {\n" +
+		"\tfmt.Println(\"hi\")\n" +
+		"}\n" +
+		"\n" +
+		"Name        Price\n" +
+		"Apple       1.20\n" +
+		"Watermelon  3\n" +
+		"\n" +
+		"[Logo]"
+	got := htmls.ToPlainText(root, htmls.PlainTextOptions{LinkURLs: true, ImageAlt: true})
+	if got != want {
+		t.Errorf("Unexpected plain text:\n%s\n\nwant:\n%s", got, want)
+	}
+
+	got = htmls.ToPlainText(root, htmls.PlainTextOptions{})
+	if strings.Contains(got, "https://") || strings.Contains(got, "[Logo]") {
+		t.Errorf("Didn't expect link URLs or images:\n%s", got)
+	}
+}
+
+func TestToMarkdownEscapes(t *testing.T) {
+	root, _ := html.Parse(strings.NewReader(`<p>a*b_c [x]</p><table><tr><td>a|b</td></tr></table>`))
+
+	want := "a\\*b\\_c \\[x\\]\n\n|  |\n| --- |\n| a\\|b |"
+	if got := htmls.ToMarkdown(root); got != want {
+		t.Errorf("Unexpected markdown %q, want %q", got, want)
+	}
+}
+
+func TestToMarkdownEdgeCases(t *testing.T) {
+	tests := []struct {
+		html, want string
+	}{
+		{`<b>Note: </b>x`, "**Note:** x"},
+		{`a<b> bold </b>b`, "a **bold** b"},
+		{`a <i><b> both</b></i>`, "a _**both**_"},
+		{`a <b><script>z</script></b> b`, "a b"},
+		{`<i><b> </b>x</i>`, "_x_"},
+		{`<b><i><style>p {}</style></i>y</b>`, "**y**"},
+		{"<code>a`b</code>", "``a`b``"},
+		{"<code>`x`</code>", "`` `x` ``"},
+		{"<pre>```\ncode\n```</pre>", "````\n```\ncode\n```\n````"},
+		{`<a href="/a b(1).html">x</a>`, "[x](</a b(1).html>)"},
+		{`<img src="a<b>.png" alt="a">`, "![a](<a\\<b\\>.png>)"},
+		{`<p># not a heading</p><p>> not quoted</p><p>- not a list</p>`, "\\# not a heading\n\n\\> not quoted\n\n\\- not a list"},
+		{`<p>1. first</p><p>2024) year</p><p>a 1. b</p>`, "1\\. first\n\n2024\\) year\n\na 1. b"},
+		{`<ul><li>- dash</li></ul>`, "- \\- dash"},
+	}
+	for _, test := range tests {
+		root, _ := html.Parse(strings.NewReader(test.html))
+		if got := htmls.ToMarkdown(root); got != test.want {
+			t.Errorf("%s: expected %q but got %q", test.html, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package htmls
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// SetAttr sets the value of an HTML attribute, adding it if needed.
+//
+//	for _, img := range htmls.FindAll(root, htmls.ByTag(atom.Img)) {
+//	    htmls.SetAttr(img, "loading", "lazy")
+//	}
+func SetAttr(node *html.Node, key, val string) {
+	for i, a := range node.Attr {
+		if a.Namespace == "" && a.Key == key {
+			node.Attr[i].Val = val
+			return
+		}
+	}
+	node.Attr = append(node.Attr, html.Attribute{Key: key, Val: val})
+}
+
+// RemoveAttr removes an HTML attribute.
+func RemoveAttr(node *html.Node, key string) {
+	keep := node.Attr[:0]
+	for _, a := range node.Attr {
+		if a.Namespace != "" || a.Key != key {
+			keep = append(keep, a)
+		}
+	}
+	node.Attr = keep
+}
+
+// AddClass adds classes to the class attribute of a node, skipping those it already has.
+func AddClass(node *html.Node, classes ...string) {
+	current := strings.Fields(Attr(node, "class"))
+	for _, c := range classes {
+		if c != "" && !containsString(current, c) {
+			current = append(current, c)
+		}
+	}
+	SetAttr(node, "class", strings.Join(current, " "))
+}
+
+// RemoveClass removes classes from the class attribute of a node. The
+// attribute is removed once it is empty.
+func RemoveClass(node *html.Node, classes ...string) {
+	var keep []string
+	for _, c := range strings.Fields(Attr(node, "class")) {
+		if !containsString(classes, c) {
+			keep = append(keep, c)
+		}
+	}
+	if len(keep) == 0 {
+		RemoveAttr(node, "class")
+		return
+	}
+	SetAttr(node, "class", strings.Join(keep, " "))
+}
+
+// Remove detaches a node, along with its children, from its parent.
+//
+//	for _, ad := range htmls.FindAll(root, htmls.ByClass("ad")) {
+//	    htmls.Remove(ad)
+//	}
+func Remove(node *html.Node) {
+	if node.Parent != nil {
+		node.Parent.RemoveChild(node)
+	}
+}
+
+// InsertBefore inserts nodes as the previous siblings of node, detaching
+// them from where they were first. It does nothing if node has no parent.
+func InsertBefore(node *html.Node, nodes ...*html.Node) {
+	if node.Parent == nil {
+		return
+	}
+	for _, n := range nodes {
+		if n == node {
+			continue
+		}
+		Remove(n)
+		node.Parent.InsertBefore(n, node)
+	}
+}
+
+// InsertAfter inserts nodes as the next siblings of node, detaching them
+// from where they were first. It does nothing if node has no parent.
+func InsertAfter(node *html.Node, nodes ...*html.Node) {
+	if node.Parent == nil {
+		return
+	}
+	// the next sibling may be one of the nodes
+	for _, n := range nodes {
+		if n != node {
+			Remove(n)
+		}
+	}
+	next := node.NextSibling
+	for _, n := range nodes {
+		if n != node {
+			node.Parent.InsertBefore(n, next)
+		}
+	}
+}
+
+// ReplaceWith replaces node with nodes, which may be none, and detaches it.
+// It does nothing if node has no parent.
+func ReplaceWith(node *html.Node, nodes ...*html.Node) {
+	if node.Parent == nil {
+		return
+	}
+	InsertBefore(node, nodes...)
+	Remove(node)
+}
+
+// Wrap puts wrapper in the place of node and node inside of wrapper, after
+// its existing children. A node without a parent is only moved into wrapper.
+//
+//	htmls.Wrap(table, &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div})
+func Wrap(node, wrapper *html.Node) {
+	InsertBefore(node, wrapper)
+	Remove(node)
+	wrapper.AppendChild(node)
+}
+
+// OuterHTML renders a node and its children.
+func OuterHTML(node *html.Node) string {
+	var buf bytes.Buffer
+	// writing to a bytes.Buffer can't fail
+	_ = html.Render(&buf, node)
+	return buf.String()
+}
+
+// InnerHTML renders the children of a node.
+func InnerHTML(node *html.Node) string {
+	var buf bytes.Buffer
+	for c := node.FirstChild; c != nil; c = c.NextSibling {
+		_ = html.Render(&buf, c)
+	}
+	return buf.String()
+}
+
+// SetInnerHTML replaces the children of a node with s, parsed as HTML in the
+// context of the node.
+func SetInnerHTML(node *html.Node, s string) error {
+	nodes, err := parseFragment(s, node)
+	if err != nil {
+		return err
+	}
+	for c := node.FirstChild; c != nil; c = node.FirstChild {
+		node.RemoveChild(c)
+	}
+	for _, n := range nodes {
+		node.AppendChild(n)
+	}
+	return nil
+}
+
+// SetOuterHTML replaces a node with s, parsed as HTML in the context of the
+// node's parent. The node must have a parent.
+//
+//	err := htmls.SetOuterHTML(video, `<a href="/watch">Watch the video</a>`)
+func SetOuterHTML(node *html.Node, s string) error {
+	if node.Parent == nil {
+		return errors.New("htmls: SetOuterHTML on a node without a parent")
+	}
+	nodes, err := parseFragment(s, node.Parent)
+	if err != nil {
+		return err
+	}
+	ReplaceWith(node, nodes...)
+	return nil
+}
+
+// parseFragment parses s as the content of context. Fragments of documents
+// are parsed as the content of a body element.
+func parseFragment(s string, context *html.Node) ([]*html.Node, error) {
+	if context == nil || context.Type != html.ElementNode {
+		context = &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	}
+	return html.ParseFragment(strings.NewReader(s), context)
+}
@@ -0,0 +1,116 @@
+package htmls_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Akagi201/utils-go/htmls"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+func parseBody(t *testing.T, s string) *html.Node {
+	root, err := html.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := htmls.Find(root, htmls.ByTag(atom.Body))
+	return body
+}
+
+func TestAttrMutations(t *testing.T) {
+	body := parseBody(t, `<img src="a.png" class="x  big" alt="a">`)
+	img, _ := htmls.Find(body, htmls.ByTag(atom.Img))
+
+	htmls.SetAttr(img, "src", "https://cdn.example.com/a.png")
+	htmls.SetAttr(img, "loading", "lazy")
+	htmls.RemoveAttr(img, "alt")
+	htmls.AddClass(img, "big", "round")
+	htmls.RemoveClass(img, "x")
+
+	want := `<img src="https://cdn.example.com/a.png" class="big round" loading="lazy"/>`
+	if got := htmls.InnerHTML(body); got != want {
+		t.Errorf("Expected %s but got %s", want, got)
+	}
+
+	htmls.RemoveClass(img, "big", "round")
+	if _, ok := htmls.Find(body, htmls.HasAttr("class")); ok {
+		t.Error("Expected the empty class attribute to be removed")
+	}
+}
+
+func TestTreeMutations(t *testing.T) {
+	body := parseBody(t, `<p id="a">a</p><div class="ad">ad</div><p id="b">b</p><p id="c">c</p>`)
+	a, _ := htmls.Find(body, htmls.ByID("a"))
+	b, _ := htmls.Find(body, htmls.ByID("b"))
+	c, _ := htmls.Find(body, htmls.ByID("c"))
+	ad, _ := htmls.Find(body, htmls.ByClass("ad"))
+
+	htmls.Remove(ad)
+	htmls.InsertAfter(a, c)
+	htmls.InsertBefore(a, &html.Node{Type: html.TextNode, Data: "start "})
+	htmls.Wrap(b, &html.Node{Type: html.ElementNode, Data: "section", DataAtom: atom.Section})
+	htmls.ReplaceWith(a, &html.Node{Type: html.TextNode, Data: "A"}, &html.Node{Type: html.TextNode, Data: "!"})
+
+	want := `start A!<p id="c">c</p><section><p id="b">b</p></section>`
+	if got := htmls.InnerHTML(body); got != want {
+		t.Errorf("Expected %s but got %s", want, got)
+	}
+	if got := htmls.OuterHTML(b); got != `<p id="b">b</p>` {
+		t.Errorf("Unexpected OuterHTML %s", got)
+	}
+	if a.Parent != nil || ad.Parent != nil {
+		t.Error("Expected removed nodes to be detached")
+	}
+}
+
+func TestTreeMutationsEdgeCases(t *testing.T) {
+	body := parseBody(t, `<p id="a">a</p><p id="b">b</p><p id="c">c</p>`)
+	a, _ := htmls.Find(body, htmls.ByID("a"))
+	b, _ := htmls.Find(body, htmls.ByID("b"))
+	c, _ := htmls.Find(body, htmls.ByID("c"))
+
+	// the next sibling of a is moved
+	htmls.InsertAfter(a, c, b)
+	if got, want := htmls.InnerHTML(body), `<p id="a">a</p><p id="c">c</p><p id="b">b</p>`; got != want {
+		t.Errorf("Expected %s but got %s", want, got)
+	}
+	htmls.InsertBefore(a, a, b)
+	if got, want := htmls.InnerHTML(body), `<p id="b">b</p><p id="a">a</p><p id="c">c</p>`; got != want {
+		t.Errorf("Expected %s but got %s", want, got)
+	}
+
+	// detached nodes are left alone
+	orphan := &html.Node{Type: html.ElementNode, Data: "p", DataAtom: atom.P}
+	htmls.InsertBefore(orphan, a)
+	htmls.InsertAfter(orphan, a)
+	htmls.ReplaceWith(orphan, a)
+	if a.Parent != body {
+		t.Error("Expected no change with a detached node")
+	}
+	if err := htmls.SetOuterHTML(orphan, "<b>x</b>"); err == nil {
+		t.Error("Expected an error for a detached node")
+	}
+}
+
+func TestSetHTML(t *testing.T) {
+	body := parseBody(t, `<table><tr id="row"><td>old</td></tr></table><div id="d">x</div>`)
+	row, _ := htmls.Find(body, htmls.ByID("row"))
+	d, _ := htmls.Find(body, htmls.ByID("d"))
+
+	// <td> only parses in the context of a table row
+	if err := htmls.SetInnerHTML(row, `<td>1</td><td>2</td>`); err != nil {
+		t.Fatal(err)
+	}
+	if got := htmls.InnerHTML(row); got != `<td>1</td><td>2</td>` {
+		t.Errorf("Unexpected row %s", got)
+	}
+
+	if err := htmls.SetOuterHTML(d, `<span>y</span> <b>z</b>`); err != nil {
+		t.Fatal(err)
+	}
+	want := `<table><tbody><tr id="row"><td>1</td><td>2</td></tr></tbody></table><span>y</span> <b>z</b>`
+	if got := htmls.InnerHTML(body); got != want {
+		t.Errorf("Expected %s but got %s", want, got)
+	}
+}
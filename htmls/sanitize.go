@@ -0,0 +1,202 @@
+package htmls
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Policy is an allow-list of the elements and attributes kept by Sanitize.
+//
+// Elements which are not allowed are unwrapped, keeping their sanitized
+// content, except for script, style, iframe, svg, math and other elements
+// whose content is not plain markup: those are removed entirely. Comments
+// are always removed. URL attributes like href and src are dropped if their
+// scheme is not allowed.
+type Policy struct {
+	// Elements maps each allowed element to the attributes allowed on it.
+	Elements map[atom.Atom][]string
+	// GlobalAttrs are allowed on every allowed element.
+	GlobalAttrs []string
+	// URLSchemes are the schemes allowed in URL attributes, e.g. "https".
+	// Relative URLs are always allowed.
+	URLSchemes []string
+	// AddRelNoFollow sets rel="nofollow" on links with an href.
+	AddRelNoFollow bool
+}
+
+// NewUGCPolicy returns a policy for user generated content: text formatting,
+// lists, tables, links and images, with http, https and mailto URLs only and
+// rel="nofollow" added to links.
+func NewUGCPolicy() *Policy {
+	p := &Policy{
+		Elements: map[atom.Atom][]string{
+			atom.A:          {"href"},
+			atom.Img:        {"src", "alt", "width", "height"},
+			atom.Blockquote: {"cite"},
+			atom.Q:          {"cite"},
+			atom.Del:        {"cite", "datetime"},
+			atom.Ins:        {"cite", "datetime"},
+			atom.Ol:         {"start", "reversed", "type"},
+			atom.Td:         {"colspan", "rowspan"},
+			atom.Th:         {"colspan", "rowspan", "scope"},
+		},
+		GlobalAttrs:    []string{"title", "lang", "dir"},
+		URLSchemes:     []string{"http", "https", "mailto"},
+		AddRelNoFollow: true,
+	}
+	for _, a := range []atom.Atom{
+		atom.P, atom.Br, atom.Hr, atom.Div, atom.Span, atom.Pre, atom.Code, atom.Kbd, atom.Samp,
+		atom.B, atom.Strong, atom.I, atom.Em, atom.U, atom.S, atom.Strike, atom.Small, atom.Mark,
+		atom.Sub, atom.Sup, atom.Abbr, atom.Cite, atom.Dfn, atom.Figure, atom.Figcaption,
+		atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6,
+		atom.Ul, atom.Li, atom.Dl, atom.Dt, atom.Dd,
+		atom.Table, atom.Caption, atom.Thead, atom.Tbody, atom.Tfoot, atom.Tr,
+	} {
+		p.Elements[a] = nil
+	}
+	return p
+}
+
+// NewStrictPolicy returns a policy which allows no elements at all, leaving only text.
+func NewStrictPolicy() *Policy {
+	return &Policy{Elements: map[atom.Atom][]string{}}
+}
+
+// Sanitize returns the sanitized form of an HTML fragment.
+//
+//	safe := htmls.NewUGCPolicy().Sanitize(`<a href="javascript:alert(1)" onclick="x()">hi</a>`)
+//	// safe == "<a>hi</a>"
+func (p *Policy) Sanitize(s string) string {
+	var buf bytes.Buffer
+	// neither reading from a strings.Reader nor writing to a bytes.Buffer can fail
+	_ = p.SanitizeReader(&buf, strings.NewReader(s))
+	return buf.String()
+}
+
+// SanitizeReader parses an HTML fragment from r as the content of a body
+// element and writes the sanitized form to w.
+func (p *Policy) SanitizeReader(w io.Writer, r io.Reader) error {
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(r, body)
+	if err != nil {
+		return err
+	}
+	for _, n := range nodes {
+		body.AppendChild(n)
+	}
+	p.SanitizeNode(body)
+	for c := body.FirstChild; c != nil; c = c.NextSibling {
+		if err := html.Render(w, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SanitizeNode sanitizes the descendants of node in place. The node itself is left alone.
+func (p *Policy) SanitizeNode(node *html.Node) {
+	for c := node.FirstChild; c != nil; {
+		next := c.NextSibling
+		switch {
+		case c.Type == html.TextNode:
+		case c.Type != html.ElementNode || c.Namespace != "" || isUnsafeElement(c.DataAtom):
+			node.RemoveChild(c)
+		default:
+			p.SanitizeNode(c)
+			if _, ok := p.Elements[c.DataAtom]; ok && c.DataAtom != 0 {
+				p.sanitizeAttrs(c)
+				break
+			}
+			for gc := c.FirstChild; gc != nil; gc = c.FirstChild {
+				c.RemoveChild(gc)
+				node.InsertBefore(gc, c)
+			}
+			node.RemoveChild(c)
+		}
+		c = next
+	}
+}
+
+func (p *Policy) sanitizeAttrs(n *html.Node) {
+	allowed := p.Elements[n.DataAtom]
+	keep := n.Attr[:0]
+	for _, a := range n.Attr {
+		if a.Namespace != "" || !containsString(allowed, a.Key) && !containsString(p.GlobalAttrs, a.Key) {
+			continue
+		}
+		if isURLAttr(a.Key) {
+			v, ok := p.safeURL(a.Val)
+			if !ok {
+				continue
+			}
+			a.Val = v
+		}
+		keep = append(keep, a)
+	}
+	n.Attr = keep
+
+	if _, ok := attr(n, "href"); !ok || !p.AddRelNoFollow || n.DataAtom != atom.A {
+		return
+	}
+	for i, a := range n.Attr {
+		if a.Key == "rel" {
+			if !containsString(strings.Fields(a.Val), "nofollow") {
+				n.Attr[i].Val = strings.TrimSpace(a.Val + " nofollow")
+			}
+			return
+		}
+	}
+	n.Attr = append(n.Attr, html.Attribute{Key: "rel", Val: "nofollow"})
+}
+
+// safeURL normalizes a URL the way browsers do before looking at its scheme,
+// and reports whether the scheme is allowed.
+func (p *Policy) safeURL(s string) (string, bool) {
+	s = strings.TrimFunc(s, func(r rune) bool { return r <= ' ' })
+	s = strings.NewReplacer("\t", "", "\n", "", "\r", "").Replace(s)
+
+	i := strings.IndexAny(s, ":/?#")
+	if i < 0 || s[i] != ':' {
+		return s, true
+	}
+	scheme := strings.ToLower(s[:i])
+	for _, allowed := range p.URLSchemes {
+		if scheme == allowed {
+			return s, true
+		}
+	}
+	return "", false
+}
+
+func isURLAttr(key string) bool {
+	switch key {
+	case "href", "src", "cite", "action", "formaction", "poster", "background", "longdesc", "usemap", "data":
+		return true
+	}
+	return false
+}
+
+// isUnsafeElement reports whether an element is removed along with its content.
+func isUnsafeElement(a atom.Atom) bool {
+	switch a {
+	case atom.Script, atom.Style, atom.Iframe, atom.Frame, atom.Frameset, atom.Object, atom.Embed,
+		atom.Applet, atom.Noscript, atom.Noembed, atom.Noframes, atom.Template, atom.Xmp,
+		atom.Plaintext, atom.Textarea, atom.Title, atom.Head, atom.Meta, atom.Link, atom.Base,
+		atom.Svg, atom.Math, atom.Select, atom.Button, atom.Input:
+		return true
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
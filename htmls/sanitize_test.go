@@ -0,0 +1,95 @@
+package htmls_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Akagi201/utils-go/htmls"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+func TestSanitizeUGCPolicy(t *testing.T) {
+	p := htmls.NewUGCPolicy()
+
+	cases := []struct {
+		in, want string
+	}{
+		{`<p>safe <b>bold</b> &amp; <i title="x">it</i></p>`, `<p>safe <b>bold</b> &amp; <i title="x">it</i></p>`},
+		{`<a href="http://example.com" target="_blank">x</a>`, `<a href="http://example.com" rel="nofollow">x</a>`},
+		{`<a href="/local#top">x</a>`, `<a href="/local#top" rel="nofollow">x</a>`},
+		{`<a href="mailto:a@example.com">x</a>`, `<a href="mailto:a@example.com" rel="nofollow">x</a>`},
+		{`<custom><em>kept</em> text</custom>`, `<em>kept</em> text`},
+		{`<table><tr><td colspan="2" onclick="x()">c</td></tr></table>`, `<table><tbody><tr><td colspan="2">c</td></tr></tbody></table>`},
+	}
+	for _, c := range cases {
+		if got := p.Sanitize(c.in); got != c.want {
+			t.Errorf("Sanitize(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestSanitizeXSSVectors(t *testing.T) {
+	p := htmls.NewUGCPolicy()
+
+	cases := []struct {
+		in, want string
+	}{
+		{`<script>alert(1)</script>`, ``},
+		{`<SCRIPT SRC=//xss.example/x.js></SCRIPT>`, ``},
+		{`<img src=x onerror=alert(1)>`, `<img src="x"/>`},
+		{`<img src="javascript:alert(1)">`, `<img/>`},
+		{`<a href="javascript:alert(1)">x</a>`, `<a>x</a>`},
+		{`<a href="  JaVaScRiPt:alert(1)">x</a>`, `<a>x</a>`},
+		{`<a href="java&#x09;script:alert(1)">x</a>`, `<a>x</a>`},
+		{`<a href="jav&#x0A;ascript:alert(1)">x</a>`, `<a>x</a>`},
+		{`<a href="&#106;&#97;vascript:alert(1)">x</a>`, `<a>x</a>`},
+		{`<a href="&#x01;javascript:alert(1)">x</a>`, `<a>x</a>`},
+		{`<a href="data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==">x</a>`, `<a>x</a>`},
+		{`<a href="vbscript:msgbox(1)">x</a>`, `<a>x</a>`},
+		{`<svg><script>alert(1)</script></svg>`, ``},
+		{`<svg onload=alert(1)>`, ``},
+		{`<math><mi xlink:href="javascript:alert(1)">x</mi></math>`, ``},
+		{`<iframe src="javascript:alert(1)"></iframe>`, ``},
+		{`<object data="javascript:alert(1)"></object>`, ``},
+		{`<embed src="javascript:alert(1)">`, ``},
+		{`<style>@import 'http://xss.example/x.css';</style>`, ``},
+		{`<div style="background:url(javascript:alert(1))">x</div>`, `<div>x</div>`},
+		{`<!--<img src=x onerror=alert(1)>-->`, ``},
+		{`<noscript><p title="</noscript><img src=x onerror=alert(1)>"></noscript>`, `<img src="x"/>&#34;&gt;`},
+		{`<img src="x" alt="&quot; onerror=&quot;alert(1)">`, `<img src="x" alt="&#34; onerror=&#34;alert(1)"/>`},
+		{`<form action="javascript:alert(1)"><input type="submit"><button>b</button></form>`, ``},
+		{`<body onload=alert(1)>text`, `text`},
+		{`<xmp><script>alert(1)</script></xmp>`, ``},
+		{`<p>a<textarea><script>alert(1)</script></textarea></p>`, `<p>a</p>`},
+		{`<<script>script>alert(1)<</script>/script>`, `&lt;/script&gt;`},
+		{`<a href="http://example.com" rel="author">x</a>`, `<a href="http://example.com" rel="nofollow">x</a>`},
+	}
+	for _, c := range cases {
+		if got := p.Sanitize(c.in); got != c.want {
+			t.Errorf("Sanitize(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestSanitizeStrictPolicy(t *testing.T) {
+	got := htmls.NewStrictPolicy().Sanitize(`<h1>Title</h1><p>Hello <a href="/x">world</a><script>x()</script> &lt;3</p>`)
+	if got != "TitleHello world &lt;3" {
+		t.Errorf("Unexpected strict result %q", got)
+	}
+}
+
+func TestSanitizeNode(t *testing.T) {
+	root, _ := html.Parse(strings.NewReader(`<div id="c"><p onclick="x()">hi</p><script>x()</script></div>`))
+	div, _ := htmls.Find(root, htmls.ByID("c"))
+
+	p := &htmls.Policy{Elements: map[atom.Atom][]string{atom.P: nil}}
+	p.SanitizeNode(div)
+
+	if htmls.Attr(div, "id") != "c" {
+		t.Error("Expected the node itself to be left alone")
+	}
+	if div.FirstChild == nil || div.FirstChild.DataAtom != atom.P || len(div.FirstChild.Attr) != 0 || div.FirstChild.NextSibling != nil {
+		t.Error("Expected only a bare p to be left")
+	}
+}
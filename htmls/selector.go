@@ -0,0 +1,564 @@
+package htmls
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Select returns all nodes below node, in document order, which match the CSS selector.
+// Unlike FindAll, nested matches are returned as well.
+//
+//	links, err := htmls.Select(root, "div.item > a[href^='http']:nth-child(2)")
+func Select(node *html.Node, selector string) ([]*html.Node, error) {
+	mf, err := Compile(selector)
+	if err != nil {
+		return nil, err
+	}
+	return selectAll(node, mf), nil
+}
+
+// SelectOne returns the first node below node which matches the CSS selector.
+// The returned node is nil if there is no match.
+func SelectOne(node *html.Node, selector string) (*html.Node, error) {
+	mf, err := Compile(selector)
+	if err != nil {
+		return nil, err
+	}
+	return selectFirst(node, mf), nil
+}
+
+// selectAll is like FindAllNested but excludes node itself.
+func selectAll(node *html.Node, mf MatchFunc) []*html.Node {
+	var matched []*html.Node
+	for c := node.FirstChild; c != nil; c = c.NextSibling {
+		matched = append(matched, FindAllNested(c, mf)...)
+	}
+	return matched
+}
+
+// selectFirst is like Find but excludes node itself.
+func selectFirst(node *html.Node, mf MatchFunc) *html.Node {
+	for c := node.FirstChild; c != nil; c = c.NextSibling {
+		if n, ok := Find(c, mf); ok {
+			return n
+		}
+	}
+	return nil
+}
+
+// MustCompile is like Compile but panics if the selector cannot be parsed.
+func MustCompile(selector string) MatchFunc {
+	mf, err := Compile(selector)
+	if err != nil {
+		panic(err)
+	}
+	return mf
+}
+
+// Compile parses a CSS level 3 selector group into a MatchFunc, which can be
+// used with FindAll, Find, FindParent and friends.
+//
+// Supported are type, universal, id, class and attribute selectors with the
+// =, ~=, |=, ^=, $= and *= operators, the descendant, child (>), adjacent
+// sibling (+) and general sibling (~) combinators, and the pseudo-classes
+// :root, :empty, :first-child, :last-child, :only-child, :first-of-type,
+// :last-of-type, :only-of-type, :nth-child(), :nth-last-child(),
+// :nth-of-type(), :nth-last-of-type(), :not(), :link, :checked, :enabled
+// and :disabled.
+func Compile(selector string) (MatchFunc, error) {
+	p := &selectorParser{s: selector}
+	mf, err := p.parseGroup()
+	if err != nil {
+		return nil, &SelectorError{Selector: selector, Pos: p.pos, Msg: err.Error()}
+	}
+	return mf, nil
+}
+
+// SelectorError is returned when a CSS selector cannot be parsed.
+type SelectorError struct {
+	Selector string
+	Pos      int
+	Msg      string
+}
+
+func (e *SelectorError) Error() string {
+	return fmt.Sprintf("htmls: invalid selector %q at offset %d: %s", e.Selector, e.Pos, e.Msg)
+}
+
+type selectorParser struct {
+	s   string
+	pos int
+}
+
+func (p *selectorParser) eof() bool {
+	return p.pos >= len(p.s)
+}
+
+func (p *selectorParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *selectorParser) skipSpace() bool {
+	start := p.pos
+	for !p.eof() && isSpace(p.s[p.pos]) {
+		p.pos++
+	}
+	return p.pos > start
+}
+
+func (p *selectorParser) parseGroup() (MatchFunc, error) {
+	var group []MatchFunc
+	for {
+		p.skipSpace()
+		mf, err := p.parseComplex()
+		if err != nil {
+			return nil, err
+		}
+		group = append(group, mf)
+		p.skipSpace()
+		if p.eof() {
+			break
+		}
+		if p.peek() != ',' {
+			return nil, fmt.Errorf("unexpected %q", p.peek())
+		}
+		p.pos++
+	}
+	if len(group) == 1 {
+		return group[0], nil
+	}
+	return Or(group...), nil
+}
+
+// parseComplex parses compound selectors separated by combinators.
+func (p *selectorParser) parseComplex() (MatchFunc, error) {
+	mf, err := p.parseCompound()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		space := p.skipSpace()
+		if p.eof() || p.peek() == ',' || p.peek() == ')' {
+			return mf, nil
+		}
+		comb := byte(' ')
+		switch c := p.peek(); c {
+		case '>', '+', '~':
+			comb = c
+			p.pos++
+			p.skipSpace()
+		default:
+			if !space {
+				return nil, fmt.Errorf("unexpected %q", c)
+			}
+		}
+		right, err := p.parseCompound()
+		if err != nil {
+			return nil, err
+		}
+		mf = combine(mf, comb, right)
+	}
+}
+
+func combine(left MatchFunc, comb byte, right MatchFunc) MatchFunc {
+	switch comb {
+	case '>':
+		return func(n *html.Node) bool {
+			return right(n) && n.Parent != nil && left(n.Parent)
+		}
+	case '+':
+		return func(n *html.Node) bool {
+			if !right(n) {
+				return false
+			}
+			s := prevElement(n)
+			return s != nil && left(s)
+		}
+	case '~':
+		return func(n *html.Node) bool {
+			if !right(n) {
+				return false
+			}
+			for s := prevElement(n); s != nil; s = prevElement(s) {
+				if left(s) {
+					return true
+				}
+			}
+			return false
+		}
+	default:
+		return func(n *html.Node) bool {
+			if !right(n) {
+				return false
+			}
+			_, ok := FindParent(n, left)
+			return ok
+		}
+	}
+}
+
+// parseCompound parses a sequence of simple selectors, like "a.ext[href]:first-child".
+func (p *selectorParser) parseCompound() (MatchFunc, error) {
+	mfs := []MatchFunc{isElement}
+	empty := true
+
+	switch c := p.peek(); {
+	case c == '*':
+		p.pos++
+		empty = false
+	case isNameStart(c):
+		tag := strings.ToLower(p.parseIdent())
+		mfs = append(mfs, func(n *html.Node) bool { return n.Data == tag })
+		empty = false
+	}
+
+	for !p.eof() {
+		var mf MatchFunc
+		var err error
+		switch p.peek() {
+		case '#':
+			p.pos++
+			id := p.parseIdent()
+			if id == "" {
+				return nil, fmt.Errorf("expected id")
+			}
+			mf = ByID(id)
+		case '.':
+			p.pos++
+			class := p.parseIdent()
+			if class == "" {
+				return nil, fmt.Errorf("expected class name")
+			}
+			mf = ByClass(class)
+		case '[':
+			p.pos++
+			mf, err = p.parseAttr()
+		case ':':
+			p.pos++
+			mf, err = p.parsePseudo()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if mf == nil {
+			break
+		}
+		mfs = append(mfs, mf)
+		empty = false
+	}
+	if empty {
+		return nil, fmt.Errorf("expected selector")
+	}
+	return And(mfs...), nil
+}
+
+func (p *selectorParser) parseAttr() (MatchFunc, error) {
+	p.skipSpace()
+	key := strings.ToLower(p.parseIdent())
+	if key == "" {
+		return nil, fmt.Errorf("expected attribute name")
+	}
+	p.skipSpace()
+	if p.peek() == ']' {
+		p.pos++
+		return HasAttr(key), nil
+	}
+
+	op := ""
+	if c := p.peek(); c == '~' || c == '|' || c == '^' || c == '$' || c == '*' {
+		op = string(c)
+		p.pos++
+	}
+	if p.peek() != '=' {
+		return nil, fmt.Errorf("expected attribute operator")
+	}
+	p.pos++
+	op += "="
+	p.skipSpace()
+
+	var val string
+	var err error
+	if c := p.peek(); c == '"' || c == '\'' {
+		val, err = p.parseString()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		val = p.parseIdent()
+		if val == "" {
+			return nil, fmt.Errorf("expected attribute value")
+		}
+	}
+	p.skipSpace()
+	if p.peek() != ']' {
+		return nil, fmt.Errorf("expected ']'")
+	}
+	p.pos++
+
+	var test func(string) bool
+	switch op {
+	case "=":
+		test = func(s string) bool { return s == val }
+	case "~=":
+		test = func(s string) bool {
+			for _, f := range strings.Fields(s) {
+				if f == val {
+					return true
+				}
+			}
+			return false
+		}
+	case "|=":
+		test = func(s string) bool { return s == val || strings.HasPrefix(s, val+"-") }
+	case "^=":
+		test = func(s string) bool { return val != "" && strings.HasPrefix(s, val) }
+	case "$=":
+		test = func(s string) bool { return val != "" && strings.HasSuffix(s, val) }
+	case "*=":
+		test = func(s string) bool { return val != "" && strings.Contains(s, val) }
+	}
+	return func(n *html.Node) bool {
+		v, ok := attr(n, key)
+		return ok && test(v)
+	}, nil
+}
+
+func (p *selectorParser) parsePseudo() (MatchFunc, error) {
+	name := strings.ToLower(p.parseIdent())
+	switch name {
+	case "root":
+		return func(n *html.Node) bool {
+			return n.Parent != nil && n.Parent.Type == html.DocumentNode
+		}, nil
+	case "empty":
+		return func(n *html.Node) bool {
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				if c.Type == html.ElementNode || c.Type == html.TextNode && c.Data != "" {
+					return false
+				}
+			}
+			return true
+		}, nil
+	case "first-child":
+		return nth(0, 1, false, false), nil
+	case "last-child":
+		return nth(0, 1, true, false), nil
+	case "only-child":
+		first, last := nth(0, 1, false, false), nth(0, 1, true, false)
+		return func(n *html.Node) bool { return first(n) && last(n) }, nil
+	case "first-of-type":
+		return nth(0, 1, false, true), nil
+	case "last-of-type":
+		return nth(0, 1, true, true), nil
+	case "only-of-type":
+		first, last := nth(0, 1, false, true), nth(0, 1, true, true)
+		return func(n *html.Node) bool { return first(n) && last(n) }, nil
+	case "link":
+		return func(n *html.Node) bool {
+			_, ok := attr(n, "href")
+			return ok && (n.Data == "a" || n.Data == "area" || n.Data == "link")
+		}, nil
+	case "checked":
+		return func(n *html.Node) bool {
+			_, checked := attr(n, "checked")
+			_, selected := attr(n, "selected")
+			return n.Data == "input" && checked || n.Data == "option" && selected
+		}, nil
+	case "disabled", "enabled":
+		want := name == "disabled"
+		return func(n *html.Node) bool {
+			switch n.Data {
+			case "button", "input", "select", "textarea", "optgroup", "option", "fieldset":
+				_, disabled := attr(n, "disabled")
+				return disabled == want
+			}
+			return false
+		}, nil
+	case "not", "nth-child", "nth-last-child", "nth-of-type", "nth-last-of-type":
+	case "":
+		return nil, fmt.Errorf("expected pseudo-class")
+	default:
+		return nil, fmt.Errorf("unsupported pseudo-class :%s", name)
+	}
+
+	if p.peek() != '(' {
+		return nil, fmt.Errorf("expected '(' after :%s", name)
+	}
+	p.pos++
+	p.skipSpace()
+
+	var mf MatchFunc
+	if name == "not" {
+		inner, err := p.parseCompound()
+		if err != nil {
+			return nil, err
+		}
+		mf = Not(inner)
+	} else {
+		end := strings.IndexByte(p.s[p.pos:], ')')
+		if end < 0 {
+			return nil, fmt.Errorf("expected ')'")
+		}
+		a, b, err := parseNth(p.s[p.pos : p.pos+end])
+		if err != nil {
+			return nil, err
+		}
+		p.pos += end
+		last := strings.Contains(name, "last")
+		ofType := strings.HasSuffix(name, "of-type")
+		mf = nth(a, b, last, ofType)
+	}
+
+	p.skipSpace()
+	if p.peek() != ')' {
+		return nil, fmt.Errorf("expected ')'")
+	}
+	p.pos++
+	return mf, nil
+}
+
+func (p *selectorParser) parseIdent() string {
+	var sb strings.Builder
+	for !p.eof() {
+		c := p.s[p.pos]
+		switch {
+		case c == '\\' && p.pos+1 < len(p.s):
+			sb.WriteByte(p.s[p.pos+1])
+			p.pos += 2
+		case isNameStart(c) || c == '-' || c >= '0' && c <= '9':
+			sb.WriteByte(c)
+			p.pos++
+		default:
+			return sb.String()
+		}
+	}
+	return sb.String()
+}
+
+func (p *selectorParser) parseString() (string, error) {
+	quote := p.s[p.pos]
+	p.pos++
+	var sb strings.Builder
+	for !p.eof() {
+		c := p.s[p.pos]
+		switch {
+		case c == quote:
+			p.pos++
+			return sb.String(), nil
+		case c == '\\' && p.pos+1 < len(p.s):
+			sb.WriteByte(p.s[p.pos+1])
+			p.pos += 2
+		default:
+			sb.WriteByte(c)
+			p.pos++
+		}
+	}
+	return "", fmt.Errorf("unterminated string")
+}
+
+// parseNth parses the an+b notation used by :nth-child() and friends.
+func parseNth(s string) (a, b int, err error) {
+	s = strings.ToLower(strings.Join(strings.Fields(s), ""))
+	switch s {
+	case "odd":
+		return 2, 1, nil
+	case "even":
+		return 2, 0, nil
+	}
+	i := strings.IndexByte(s, 'n')
+	if i < 0 {
+		b, err = strconv.Atoi(s)
+		return 0, b, err
+	}
+	switch as := s[:i]; as {
+	case "", "+":
+		a = 1
+	case "-":
+		a = -1
+	default:
+		if a, err = strconv.Atoi(as); err != nil {
+			return 0, 0, err
+		}
+	}
+	if bs := s[i+1:]; bs != "" {
+		if bs[0] != '+' && bs[0] != '-' {
+			return 0, 0, fmt.Errorf("invalid nth expression %q", s)
+		}
+		if b, err = strconv.Atoi(bs); err != nil {
+			return 0, 0, err
+		}
+	}
+	return a, b, nil
+}
+
+// nth matches elements whose 1-based position among their element siblings
+// is a*k+b for some k >= 0. Positions are counted from the end when last is
+// set, and only among siblings of the same tag when ofType is set.
+func nth(a, b int, last, ofType bool) MatchFunc {
+	return func(n *html.Node) bool {
+		if n.Parent == nil {
+			return false
+		}
+		pos := 1
+		next := prevElement
+		if last {
+			next = nextElement
+		}
+		for s := next(n); s != nil; s = next(s) {
+			if !ofType || s.Data == n.Data {
+				pos++
+			}
+		}
+		if a == 0 {
+			return pos == b
+		}
+		k := pos - b
+		return k%a == 0 && k/a >= 0
+	}
+}
+
+func isElement(n *html.Node) bool {
+	return n.Type == html.ElementNode
+}
+
+func prevElement(n *html.Node) *html.Node {
+	for s := n.PrevSibling; s != nil; s = s.PrevSibling {
+		if s.Type == html.ElementNode {
+			return s
+		}
+	}
+	return nil
+}
+
+func nextElement(n *html.Node) *html.Node {
+	for s := n.NextSibling; s != nil; s = s.NextSibling {
+		if s.Type == html.ElementNode {
+			return s
+		}
+	}
+	return nil
+}
+
+// attr is like Attr but also reports whether the attribute is present.
+func attr(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+func isNameStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c >= 0x80 || c == '\\'
+}
@@ -0,0 +1,112 @@
+package htmls_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Akagi201/utils-go/htmls"
+	"golang.org/x/net/html"
+)
+
+const testSelectorHTML = `
+<html>
+  <body>
+    <div class="item" id="first">
+      <span>one</span>
+      <a href="http://example.com/1" rel="nofollow">ext1</a>
+      <a href="/local">local</a>
+    </div>
+    <div class="item special">
+      <a href="/x">x</a>
+      <a href="https://example.com/2" lang="en-US">ext2</a>
+      <p></p>
+    </div>
+    <ul>
+      <li>1</li><li>2</li><li>3</li><li>4</li><li>5</li>
+    </ul>
+    <form>
+      <input type="checkbox" checked>
+      <input type="text" disabled>
+    </form>
+  </body>
+</html>
+`
+
+func selectTexts(t *testing.T, selector string) []string {
+	t.Helper()
+	root, _ := html.Parse(strings.NewReader(testSelectorHTML))
+	nodes, err := htmls.Select(root, selector)
+	if err != nil {
+		t.Fatalf("Select(%q) returned error: %v", selector, err)
+	}
+	texts := make([]string, len(nodes))
+	for i, n := range nodes {
+		texts[i] = htmls.Text(n)
+	}
+	return texts
+}
+
+func TestSelect(t *testing.T) {
+	cases := []struct {
+		selector string
+		want     string
+	}{
+		{"div.item > a[href^='http']:nth-child(2)", "ext1,ext2"},
+		{"div.item > a[href^='http']", "ext1,ext2"},
+		{"#first a", "ext1,local"},
+		{".item.special a:last-of-type", "ext2"},
+		{"a[rel=nofollow]", "ext1"},
+		{"a[lang|=en]", "ext2"},
+		{`a[href$=".com/2"]`, "ext2"},
+		{"a[href*=example]", "ext1,ext2"},
+		{"div[class~=special] > :first-child", "x"},
+		{"span + a", "ext1"},
+		{"span ~ a", "ext1,local"},
+		{"li:nth-child(odd)", "1,3,5"},
+		{"li:nth-child(2n)", "2,4"},
+		{"li:nth-child(-n+2)", "1,2"},
+		{"li:nth-last-child(1)", "5"},
+		{"li:not(:first-child):not(:last-child)", "2,3,4"},
+		{"div > p:empty, span", "one,"},
+		{"input:checked, input:disabled", ","},
+		{"span, li:first-child", "one,1"},
+	}
+	for _, c := range cases {
+		got := strings.Join(selectTexts(t, c.selector), ",")
+		if got != c.want {
+			t.Errorf("Select(%q) = %q, want %q", c.selector, got, c.want)
+		}
+	}
+}
+
+func TestSelectOne(t *testing.T) {
+	root, _ := html.Parse(strings.NewReader(testSelectorHTML))
+
+	n, err := htmls.SelectOne(root, "ul > li:nth-of-type(3)")
+	if err != nil || n == nil || htmls.Text(n) != "3" {
+		t.Error("Expected to find the third li node")
+	}
+
+	n, err = htmls.SelectOne(root, "table")
+	if err != nil || n != nil {
+		t.Error("Didn't expect to find a table node")
+	}
+}
+
+func TestCompileWorksWithFindParent(t *testing.T) {
+	root, _ := html.Parse(strings.NewReader(testSelectorHTML))
+
+	span, _ := htmls.SelectOne(root, "span")
+	div, ok := htmls.FindParent(span, htmls.MustCompile("div.item"))
+	if !ok || htmls.Attr(div, "id") != "first" {
+		t.Error("Expected to find the first div node")
+	}
+}
+
+func TestCompileInvalidSelector(t *testing.T) {
+	for _, s := range []string{"", "div >", "a[href", "a[href=]", ":nope", "li:nth-child(x)", "a,,b", "div )"} {
+		if _, err := htmls.Compile(s); err == nil {
+			t.Errorf("Expected an error compiling %q", s)
+		}
+	}
+}
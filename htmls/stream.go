@@ -0,0 +1,205 @@
+package htmls
+
+import (
+	"context"
+	"io"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Stream tokenizes HTML from r and calls fn with each subtree whose root
+// matches mf, without building the whole document. Like FindAll, matching
+// subnodes of a matching node are not reported. Returning an error from fn
+// stops the stream and Stream returns that error.
+//
+// Memory use is bounded by the depth of the document plus the size of the
+// largest matched subtree. In exchange, mf is called on each element as soon
+// as its start tag is read: it sees the element's tag, attributes and its
+// ancestors (up to a DocumentNode), but not its children or siblings, so
+// matchers like ByText or :nth-child won't work. The ancestors don't have
+// their implied html, head and body elements, and only the most common
+// implied end tags (li, p, td, tr, option, ...) are handled.
+//
+// The subtrees passed to fn are detached and complete.
+//
+//	err := htmls.Stream(f, htmls.MustCompile("div.item a[href]"), func(n *html.Node) error {
+//	    fmt.Println(htmls.Attr(n, "href"))
+//	    return nil
+//	})
+func Stream(r io.Reader, mf MatchFunc, fn func(*html.Node) error) error {
+	z := html.NewTokenizer(r)
+	stack := []*html.Node{{Type: html.DocumentNode}}
+	var capture *html.Node
+	captureIdx := 0
+
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			if z.Err() != io.EOF {
+				return z.Err()
+			}
+			if capture != nil {
+				return fn(capture)
+			}
+			return nil
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			n := &html.Node{Type: html.ElementNode, Data: string(name), DataAtom: atom.Lookup(name)}
+			for hasAttr {
+				var key, val []byte
+				key, val, hasAttr = z.TagAttr()
+				n.Attr = append(n.Attr, html.Attribute{Key: string(key), Val: string(val)})
+			}
+
+			stack = closeImplied(stack, n.DataAtom)
+			if capture != nil && len(stack) <= captureIdx {
+				if err := fn(capture); err != nil {
+					return err
+				}
+				capture = nil
+			}
+
+			parent := stack[len(stack)-1]
+			if capture != nil {
+				parent.AppendChild(n)
+			} else {
+				n.Parent = parent
+				if mf(n) {
+					n.Parent = nil
+					capture, captureIdx = n, len(stack)
+				}
+			}
+
+			if tt == html.SelfClosingTagToken || isVoid(n.DataAtom) {
+				if capture == n {
+					if err := fn(capture); err != nil {
+						return err
+					}
+					capture = nil
+				}
+				continue
+			}
+			stack = append(stack, n)
+
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			i := len(stack) - 1
+			for ; i > 0 && stack[i].Data != string(name); i-- {
+			}
+			if i == 0 {
+				continue
+			}
+			stack = stack[:i]
+			if capture != nil && i <= captureIdx {
+				if err := fn(capture); err != nil {
+					return err
+				}
+				capture = nil
+			}
+
+		case html.TextToken, html.CommentToken, html.DoctypeToken:
+			if capture == nil {
+				continue
+			}
+			n := &html.Node{Type: html.TextNode, Data: string(z.Text())}
+			if tt == html.CommentToken {
+				n.Type = html.CommentNode
+			} else if tt == html.DoctypeToken {
+				n.Type = html.DoctypeNode
+			}
+			stack[len(stack)-1].AppendChild(n)
+		}
+	}
+}
+
+// StreamChan is like Stream but sends the matched subtrees on a channel.
+// The error channel receives at most one error and is closed, along with the
+// node channel, when the stream ends or ctx is done.
+func StreamChan(ctx context.Context, r io.Reader, mf MatchFunc) (<-chan *html.Node, <-chan error) {
+	out := make(chan *html.Node)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		defer close(out)
+		err := Stream(r, mf, func(n *html.Node) error {
+			select {
+			case out <- n:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil {
+			errc <- err
+		}
+	}()
+	return out, errc
+}
+
+// impliedEnd lists, for a start tag, the open elements it implicitly closes.
+var impliedEnd = map[atom.Atom][]atom.Atom{
+	atom.Li:         {atom.Li},
+	atom.Dt:         {atom.Dt, atom.Dd},
+	atom.Dd:         {atom.Dt, atom.Dd},
+	atom.Option:     {atom.Option},
+	atom.Optgroup:   {atom.Option, atom.Optgroup},
+	atom.Td:         {atom.Td, atom.Th},
+	atom.Th:         {atom.Td, atom.Th},
+	atom.Tr:         {atom.Td, atom.Th, atom.Tr},
+	atom.Tbody:      {atom.Td, atom.Th, atom.Tr, atom.Thead, atom.Tbody},
+	atom.Tfoot:      {atom.Td, atom.Th, atom.Tr, atom.Thead, atom.Tbody},
+	atom.P:          {atom.P},
+	atom.Div:        {atom.P},
+	atom.Ul:         {atom.P},
+	atom.Ol:         {atom.P},
+	atom.Dl:         {atom.P},
+	atom.Table:      {atom.P},
+	atom.Pre:        {atom.P},
+	atom.Form:       {atom.P},
+	atom.Section:    {atom.P},
+	atom.Article:    {atom.P},
+	atom.Header:     {atom.P},
+	atom.Footer:     {atom.P},
+	atom.Nav:        {atom.P},
+	atom.Aside:      {atom.P},
+	atom.H1:         {atom.P},
+	atom.H2:         {atom.P},
+	atom.H3:         {atom.P},
+	atom.H4:         {atom.P},
+	atom.H5:         {atom.P},
+	atom.H6:         {atom.P},
+	atom.Hr:         {atom.P},
+	atom.Blockquote: {atom.P},
+}
+
+// closeImplied pops the open elements implicitly closed by a start tag.
+func closeImplied(stack []*html.Node, a atom.Atom) []*html.Node {
+	closes := impliedEnd[a]
+	for len(stack) > 1 {
+		top := stack[len(stack)-1].DataAtom
+		found := false
+		for _, c := range closes {
+			if c == top {
+				found = true
+				break
+			}
+		}
+		if !found {
+			break
+		}
+		stack = stack[:len(stack)-1]
+	}
+	return stack
+}
+
+func isVoid(a atom.Atom) bool {
+	switch a {
+	case atom.Area, atom.Base, atom.Br, atom.Col, atom.Embed, atom.Hr, atom.Img, atom.Input,
+		atom.Keygen, atom.Link, atom.Meta, atom.Param, atom.Source, atom.Track, atom.Wbr:
+		return true
+	}
+	return false
+}
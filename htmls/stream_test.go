@@ -0,0 +1,113 @@
+package htmls_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Akagi201/utils-go/htmls"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const testStreamHTML = `<!DOCTYPE html>
+<html>
+  <body>
+    <ul class="items">
+      <li class="item"><a href="/1">one</a>
+      <li class="item"><a href="/2">two</a><img src="2.png">
+      <li class="other">skip
+    </ul>
+    <div class="item">
+      <p>three<br>
+      <p>four
+    </div>
+    <img class="item" src="5.png">
+  </body>
+</html>
+`
+
+func TestStream(t *testing.T) {
+	var got []string
+	err := htmls.Stream(strings.NewReader(testStreamHTML), htmls.ByClass("item"), func(n *html.Node) error {
+		if n.Parent != nil {
+			t.Error("Expected a detached subtree")
+		}
+		var buf bytes.Buffer
+		html.Render(&buf, n)
+		got = append(got, buf.String())
+		return nil
+	})
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+
+	want := []string{
+		`<li class="item"><a href="/1">one</a>` + "\n      </li>",
+		`<li class="item"><a href="/2">two</a><img src="2.png"/>` + "\n      </li>",
+		`<div class="item">` + "\n      <p>three<br/>\n      </p><p>four\n    </p></div>",
+		`<img class="item" src="5.png"/>`,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d subtrees but found %d: %q", len(want), len(got), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Subtree %d is %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestStreamMatchesAncestors(t *testing.T) {
+	mf := htmls.MustCompile("ul.items > li > a")
+
+	var texts []string
+	err := htmls.Stream(strings.NewReader(testStreamHTML), mf, func(n *html.Node) error {
+		texts = append(texts, htmls.Text(n))
+		return nil
+	})
+	if err != nil || strings.Join(texts, " ") != "one two" {
+		t.Errorf("Unexpected result %q (%v)", texts, err)
+	}
+}
+
+func TestStreamStopsOnError(t *testing.T) {
+	stop := errors.New("stop")
+	calls := 0
+	err := htmls.Stream(strings.NewReader(testStreamHTML), htmls.ByTag(atom.Li), func(n *html.Node) error {
+		calls++
+		return stop
+	})
+	if err != stop || calls != 1 {
+		t.Errorf("Expected to stop after the first match, got %d calls (%v)", calls, err)
+	}
+}
+
+func TestStreamChan(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	nodes, errc := htmls.StreamChan(ctx, strings.NewReader(testStreamHTML), htmls.ByTag(atom.A))
+	var hrefs []string
+	for n := range nodes {
+		hrefs = append(hrefs, htmls.Attr(n, "href"))
+	}
+	if err := <-errc; err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	if strings.Join(hrefs, " ") != "/1 /2" {
+		t.Errorf("Unexpected hrefs %q", hrefs)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	nodes, errc = htmls.StreamChan(ctx, strings.NewReader(testStreamHTML), htmls.ByTag(atom.A))
+	<-nodes
+	cancel()
+	for range nodes {
+	}
+	if err := <-errc; err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
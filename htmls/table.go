@@ -0,0 +1,311 @@
+package htmls
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// TableData is an HTML table normalized into a grid by Table.
+type TableData struct {
+	Caption string
+	// Header holds the column names, nil if the table has no header rows.
+	// Stacked header rows are joined per column, e.g. "Price Min".
+	Header []string
+	// Rows holds the text of the body cells. A cell spanning several rows or
+	// columns is repeated in each of them, and short rows are padded with
+	// empty strings, so every row has the same length.
+	Rows [][]string
+	// Cells holds the td and th elements behind Rows, nil for padding.
+	Cells [][]*html.Node
+}
+
+// Table reads node, or else the first table below node, into a grid,
+// resolving rowspan and colspan. Header rows are the rows of the thead or,
+// without a thead, the leading rows made only of th cells. Nested tables
+// are part of the text of their cell. Table returns nil if there is no table.
+//
+//	t := htmls.Table(root)
+//	for _, row := range t.Maps() {
+//	    fmt.Println(row["Name"], row["Price"])
+//	}
+func Table(node *html.Node) *TableData {
+	table, ok := Find(node, ByTag(atom.Table))
+	if !ok {
+		return nil
+	}
+
+	t := &TableData{}
+	if c, ok := findChild(table, ByTag(atom.Caption)); ok {
+		t.Caption = Text(c)
+	}
+
+	// rows are grouped by section since rowspan doesn't cross sections
+	var grid [][]*html.Node
+	headerRows := 0
+	for sec := table.FirstChild; sec != nil; sec = sec.NextSibling {
+		var rows []*html.Node
+		switch sec.DataAtom {
+		case atom.Tr:
+			rows = []*html.Node{sec}
+			// consecutive rows directly in the table form one section
+			for sec.NextSibling != nil && (sec.NextSibling.DataAtom == atom.Tr || sec.NextSibling.Type != html.ElementNode) {
+				sec = sec.NextSibling
+				if sec.DataAtom == atom.Tr {
+					rows = append(rows, sec)
+				}
+			}
+		case atom.Thead, atom.Tbody, atom.Tfoot:
+			for r := sec.FirstChild; r != nil; r = r.NextSibling {
+				if r.DataAtom == atom.Tr {
+					rows = append(rows, r)
+				}
+			}
+		default:
+			continue
+		}
+		if sec.DataAtom == atom.Thead && headerRows == len(grid) {
+			headerRows += len(rows)
+		}
+		grid = append(grid, layoutRows(rows)...)
+	}
+
+	if headerRows == 0 {
+		for headerRows < len(grid) && allHeaderCells(grid[headerRows]) {
+			headerRows++
+		}
+		if headerRows == len(grid) {
+			headerRows = 0
+		}
+	}
+
+	width := 0
+	for _, row := range grid {
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+	for i := range grid {
+		for len(grid[i]) < width {
+			grid[i] = append(grid[i], nil)
+		}
+	}
+
+	if headerRows > 0 {
+		t.Header = make([]string, width)
+		for col := range t.Header {
+			var parts []string
+			for row := 0; row < headerRows; row++ {
+				cell := grid[row][col]
+				if cell == nil || row > 0 && grid[row-1][col] == cell {
+					continue
+				}
+				if s := Text(cell); s != "" && (len(parts) == 0 || parts[len(parts)-1] != s) {
+					parts = append(parts, s)
+				}
+			}
+			t.Header[col] = strings.Join(parts, " ")
+		}
+	}
+
+	t.Cells = grid[headerRows:]
+	t.Rows = make([][]string, len(t.Cells))
+	for i, row := range t.Cells {
+		t.Rows[i] = make([]string, width)
+		for j, cell := range row {
+			if cell != nil {
+				t.Rows[i][j] = Text(cell)
+			}
+		}
+	}
+	return t
+}
+
+// layoutRows places the cells of a table section in a grid.
+func layoutRows(rows []*html.Node) [][]*html.Node {
+	grid := make([][]*html.Node, len(rows))
+	for r, tr := range rows {
+		col := 0
+		for cell := tr.FirstChild; cell != nil; cell = cell.NextSibling {
+			if cell.DataAtom != atom.Td && cell.DataAtom != atom.Th {
+				continue
+			}
+			for col < len(grid[r]) && grid[r][col] != nil {
+				col++
+			}
+			colspan := spanAttr(cell, "colspan", 1, 1000)
+			rowspan := spanAttr(cell, "rowspan", 0, 65534)
+			if rowspan == 0 || r+rowspan > len(rows) {
+				rowspan = len(rows) - r
+			}
+			for i := r; i < r+rowspan; i++ {
+				for len(grid[i]) < col+colspan {
+					grid[i] = append(grid[i], nil)
+				}
+				for j := col; j < col+colspan; j++ {
+					grid[i][j] = cell
+				}
+			}
+			col += colspan
+		}
+	}
+	return grid
+}
+
+// spanAttr reads a colspan or rowspan attribute, defaulting to 1.
+func spanAttr(n *html.Node, key string, min, max int) int {
+	v, err := strconv.Atoi(strings.TrimSpace(Attr(n, key)))
+	if err != nil || v < min {
+		return 1
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+func allHeaderCells(row []*html.Node) bool {
+	for _, cell := range row {
+		if cell != nil && cell.DataAtom != atom.Th {
+			return false
+		}
+	}
+	return len(row) > 0
+}
+
+func findChild(n *html.Node, mf MatchFunc) (*html.Node, bool) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if mf(c) {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// Maps returns each row as a map from column name to cell text. Columns
+// with an empty name are left out, and Maps returns nil for a table without
+// a header.
+func (t *TableData) Maps() []map[string]string {
+	if t.Header == nil {
+		return nil
+	}
+	maps := make([]map[string]string, len(t.Rows))
+	for i, row := range t.Rows {
+		m := map[string]string{}
+		for j, name := range t.Header {
+			if _, ok := m[name]; name != "" && !ok {
+				m[name] = row[j]
+			}
+		}
+		maps[i] = m
+	}
+	return maps
+}
+
+// Unmarshal fills the slice of structs pointed to by v with one element per
+// row. Fields are matched to columns by the header option of their `htmls`
+// tag or, failing that, by their name, ignoring case and extra whitespace.
+// The other tag options apply to the cell element as they do for Unmarshal:
+//
+//	type Product struct {
+//	    Name  string  `htmls:""`
+//	    Link  string  `htmls:"header=Name,selector=a,attr=href"`
+//	    Price float64 `htmls:"header=Price (USD),re=[\\d.]+,required"`
+//	}
+//	var products []Product
+//	err := htmls.Table(root).Unmarshal(&products)
+//
+// Fields without a tag are left alone, as are fields whose column is
+// missing unless they are required. Errors are collected into an
+// *UnmarshalError with paths like "[3].Price".
+func (t *TableData) Unmarshal(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("htmls: TableData.Unmarshal needs a non-nil slice pointer, got %T", v)
+	}
+	st := rv.Elem().Type().Elem()
+	isPtr := st.Kind() == reflect.Ptr
+	if isPtr {
+		st = st.Elem()
+	}
+	if st.Kind() != reflect.Struct {
+		return fmt.Errorf("htmls: TableData.Unmarshal needs a slice of structs, got %T", v)
+	}
+	if t.Header == nil {
+		return fmt.Errorf("htmls: table has no header")
+	}
+
+	columns := map[string]int{}
+	for i := len(t.Header) - 1; i >= 0; i-- {
+		columns[normalizeHeader(t.Header[i])] = i
+	}
+
+	type field struct {
+		index int
+		name  string
+		col   int
+		opts  *fieldOptions
+	}
+	d := &decoder{}
+	var fields []field
+	for i := 0; i < st.NumField(); i++ {
+		f := st.Field(i)
+		tag, ok := f.Tag.Lookup("htmls")
+		if !ok || tag == "-" || !f.IsExported() {
+			continue
+		}
+		opts, err := parseFieldTag(tag)
+		if err != nil {
+			d.fail(f.Name, err)
+			continue
+		}
+		name := opts.header
+		if name == "" {
+			name = f.Name
+		}
+		col, ok := columns[normalizeHeader(name)]
+		if !ok {
+			if opts.required {
+				d.fail(f.Name, fmt.Errorf("no column %q", name))
+			}
+			continue
+		}
+		fields = append(fields, field{i, f.Name, col, opts})
+	}
+	if len(d.errs) > 0 {
+		return &UnmarshalError{Errors: d.errs}
+	}
+
+	s := reflect.MakeSlice(rv.Elem().Type(), len(t.Cells), len(t.Cells))
+	for i, row := range t.Cells {
+		elem := s.Index(i)
+		if isPtr {
+			elem.Set(reflect.New(st))
+			elem = elem.Elem()
+		}
+		for _, f := range fields {
+			path := fmt.Sprintf("[%d].%s", i, f.name)
+			cell := row[f.col]
+			if cell == nil {
+				if f.opts.required {
+					d.fail(path, fmt.Errorf("empty cell"))
+				}
+				continue
+			}
+			d.decodeField(cell, elem.Field(f.index), f.opts, path)
+		}
+	}
+	rv.Elem().Set(s)
+	if len(d.errs) > 0 {
+		return &UnmarshalError{Errors: d.errs}
+	}
+	return nil
+}
+
+func normalizeHeader(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
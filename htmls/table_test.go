@@ -0,0 +1,118 @@
+package htmls_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Akagi201/utils-go/htmls"
+	"golang.org/x/net/html"
+)
+
+const testTableHTML = `
+<div>
+<table>
+  <caption>Products</caption>
+  <thead>
+    <tr><th rowspan="2">Name</th><th colspan="2">Price</th><th rowspan="2">Stock</th></tr>
+    <tr><th>Min</th><th>Max</th></tr>
+  </thead>
+  <tbody>
+    <tr><td><a href="/apple">Apple</a></td><td>1.5</td><td>2</td><td rowspan="2">yes</td></tr>
+    <tr><td><a href="/pear">Pear</a></td><td colspan="2">3</td></tr>
+    <tr><td>Plum</td><td>x</td></tr>
+  </tbody>
+</table>
+</div>
+`
+
+func TestTable(t *testing.T) {
+	root, _ := html.Parse(strings.NewReader(testTableHTML))
+	table := htmls.Table(root)
+	if table == nil {
+		t.Fatal("Expected a table")
+	}
+	if table.Caption != "Products" {
+		t.Errorf("Unexpected caption %q", table.Caption)
+	}
+	if want := []string{"Name", "Price Min", "Price Max", "Stock"}; !reflect.DeepEqual(table.Header, want) {
+		t.Errorf("Unexpected header %q", table.Header)
+	}
+	want := [][]string{
+		{"Apple", "1.5", "2", "yes"},
+		{"Pear", "3", "3", "yes"},
+		{"Plum", "x", "", ""},
+	}
+	if !reflect.DeepEqual(table.Rows, want) {
+		t.Errorf("Unexpected rows %q", table.Rows)
+	}
+	if table.Cells[2][3] != nil || table.Cells[0][3] != table.Cells[1][3] {
+		t.Error("Unexpected cells")
+	}
+
+	maps := table.Maps()
+	if len(maps) != 3 || maps[1]["Name"] != "Pear" || maps[1]["Price Max"] != "3" {
+		t.Errorf("Unexpected maps %v", maps)
+	}
+}
+
+func TestTableHeaderDetection(t *testing.T) {
+	root, _ := html.Parse(strings.NewReader(`
+		<table><tr><th>A</th><th>B</th></tr><tr><td>1</td><td>2</td></tr></table>
+		<table id="plain"><tr><td>1</td><td>2</td></tr></table>`))
+
+	table := htmls.Table(root)
+	if !reflect.DeepEqual(table.Header, []string{"A", "B"}) || len(table.Rows) != 1 {
+		t.Errorf("Unexpected table %+v", table)
+	}
+
+	plain, _ := htmls.Find(root, htmls.ByID("plain"))
+	table = htmls.Table(plain)
+	if table.Header != nil || len(table.Rows) != 1 || table.Maps() != nil {
+		t.Errorf("Unexpected table without header %+v", table)
+	}
+
+	if htmls.Table(&html.Node{Type: html.DocumentNode}) != nil {
+		t.Error("Expected nil without a table")
+	}
+}
+
+func TestTableUnmarshal(t *testing.T) {
+	root, _ := html.Parse(strings.NewReader(testTableHTML))
+	table := htmls.Table(root)
+
+	type product struct {
+		Name    string  `htmls:""`
+		Link    string  `htmls:"header=name,selector=a,attr=href"`
+		Min     float64 `htmls:"header=Price  Min"`
+		InStock string  `htmls:"header=Stock"`
+		Missing string  `htmls:"header=Color"`
+		Ignored string
+	}
+
+	var products []*product
+	err := table.Unmarshal(&products)
+	var ue *htmls.UnmarshalError
+	if !errors.As(err, &ue) || len(ue.Errors) != 1 || ue.Errors[0].Field != "[2].Min" {
+		t.Fatalf("Expected one error for [2].Min but got %v", err)
+	}
+	want := []*product{
+		{Name: "Apple", Link: "/apple", Min: 1.5, InStock: "yes"},
+		{Name: "Pear", Link: "/pear", Min: 3, InStock: "yes"},
+		{Name: "Plum"},
+	}
+	if !reflect.DeepEqual(products, want) {
+		t.Errorf("Unexpected products %+v %+v %+v", *products[0], *products[1], *products[2])
+	}
+
+	var required []struct {
+		Color string `htmls:"required"`
+	}
+	if err := table.Unmarshal(&required); err == nil || !strings.Contains(err.Error(), `Color: no column "Color"`) {
+		t.Errorf("Expected a missing column error but got %v", err)
+	}
+	if err := table.Unmarshal(products); err == nil {
+		t.Error("Expected an error for a non-pointer")
+	}
+}
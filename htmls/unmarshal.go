@@ -0,0 +1,311 @@
+package htmls
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// Unmarshal fills the struct pointed to by v with data found below node,
+// driven by `htmls` struct tags. A tag is a comma separated list of options:
+//
+//	selector=CSS  the node to read, relative to the enclosing node (default: the enclosing node)
+//	attr=NAME     read an attribute instead of the text
+//	re=REGEXP     keep only the first submatch (or the whole match) of the value
+//	format=LAYOUT time layout for time.Time fields (default: time.RFC3339)
+//	required      report an error if the selector matches nothing
+//	header=NAME   the table column to read, used by TableData.Unmarshal only
+//
+// Struct fields are filled recursively with their selector as the enclosing node,
+// and slice fields get one element per match. Values are converted to strings,
+// bools, numbers, time.Time, time.Duration, encoding.TextUnmarshaler and
+// *html.Node as needed. Fields without a tag are left alone.
+//
+//	type Article struct {
+//	    Title string `htmls:"selector=td.title > a"`
+//	    URL   string `htmls:"selector=td.title > a,attr=href"`
+//	    Score int    `htmls:"selector=span.score,re=\\d+"`
+//	}
+//	var page struct {
+//	    Articles []Article `htmls:"selector=tr.athing"`
+//	}
+//	err := htmls.Unmarshal(root, &page)
+//
+// Conversion failures don't stop decoding; they are all collected into an *UnmarshalError.
+func Unmarshal(node *html.Node, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("htmls: Unmarshal needs a non-nil struct pointer, got %T", v)
+	}
+
+	d := &decoder{}
+	d.decodeStruct(node, rv.Elem(), "")
+	if len(d.errs) > 0 {
+		return &UnmarshalError{Errors: d.errs}
+	}
+	return nil
+}
+
+// FieldError describes a field which could not be filled by Unmarshal.
+type FieldError struct {
+	// Field is the path to the field, like "Articles[3].Score".
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// UnmarshalError is returned by Unmarshal when one or more fields could not be filled.
+type UnmarshalError struct {
+	Errors []*FieldError
+}
+
+func (e *UnmarshalError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		msgs[i] = fe.Error()
+	}
+	return "htmls: " + strings.Join(msgs, "; ")
+}
+
+var (
+	nodeType            = reflect.TypeOf((*html.Node)(nil))
+	timeType            = reflect.TypeOf(time.Time{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+type fieldOptions struct {
+	selector MatchFunc
+	attr     string
+	re       *regexp.Regexp
+	format   string
+	header   string
+	required bool
+}
+
+type decoder struct {
+	errs []*FieldError
+}
+
+func (d *decoder) fail(path string, err error) {
+	d.errs = append(d.errs, &FieldError{Field: path, Err: err})
+}
+
+func (d *decoder) decodeStruct(node *html.Node, v reflect.Value, path string) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, ok := f.Tag.Lookup("htmls")
+		if !ok || tag == "-" || !f.IsExported() {
+			continue
+		}
+		fpath := f.Name
+		if path != "" {
+			fpath = path + "." + f.Name
+		}
+		opts, err := parseFieldTag(tag)
+		if err != nil {
+			d.fail(fpath, err)
+			continue
+		}
+		d.decodeField(node, v.Field(i), opts, fpath)
+	}
+}
+
+// decodeField fills a field from the node, or the nodes, its selector matches below node.
+func (d *decoder) decodeField(node *html.Node, fv reflect.Value, opts *fieldOptions, path string) {
+	if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() != reflect.Uint8 {
+		nodes := []*html.Node{node}
+		if opts.selector != nil {
+			nodes = selectAll(node, opts.selector)
+		}
+		if len(nodes) == 0 && opts.required {
+			d.fail(path, fmt.Errorf("no node matches"))
+			return
+		}
+		s := reflect.MakeSlice(fv.Type(), len(nodes), len(nodes))
+		for j, n := range nodes {
+			d.decodeValue(n, s.Index(j), opts, fmt.Sprintf("%s[%d]", path, j))
+		}
+		fv.Set(s)
+		return
+	}
+
+	n := node
+	if opts.selector != nil {
+		n = selectFirst(node, opts.selector)
+	}
+	if n == nil {
+		if opts.required {
+			d.fail(path, fmt.Errorf("no node matches"))
+		}
+		return
+	}
+	d.decodeValue(n, fv, opts, path)
+}
+
+func (d *decoder) decodeValue(n *html.Node, v reflect.Value, opts *fieldOptions, path string) {
+	t := v.Type()
+	switch {
+	case t == nodeType:
+		v.Set(reflect.ValueOf(n))
+		return
+	case t.Kind() == reflect.Ptr:
+		p := reflect.New(t.Elem())
+		d.decodeValue(n, p.Elem(), opts, path)
+		v.Set(p)
+		return
+	case t.Kind() == reflect.Struct && t != timeType && !reflect.PtrTo(t).Implements(textUnmarshalerType):
+		d.decodeStruct(n, v, path)
+		return
+	}
+
+	var s string
+	if opts.attr != "" {
+		s = Attr(n, opts.attr)
+	} else {
+		s = Text(n)
+	}
+	if opts.re != nil {
+		m := opts.re.FindStringSubmatch(s)
+		switch {
+		case m == nil:
+			d.fail(path, fmt.Errorf("%q does not match %q", s, opts.re))
+			return
+		case len(m) > 1:
+			s = m[1]
+		default:
+			s = m[0]
+		}
+	}
+
+	if err := setValue(v, s, opts); err != nil {
+		d.fail(path, err)
+	}
+}
+
+func setValue(v reflect.Value, s string, opts *fieldOptions) error {
+	switch v.Type() {
+	case timeType:
+		layout := opts.format
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		tm, err := time.Parse(layout, strings.TrimSpace(s))
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(tm))
+		return nil
+	case durationType:
+		dur, err := time.ParseDuration(strings.TrimSpace(s))
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(dur))
+		return nil
+	}
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		v.SetBytes([]byte(s))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(s))
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(strings.TrimSpace(s), 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(strings.TrimSpace(s), 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// parseFieldTag parses an `htmls` struct tag. Since selectors and regexps may
+// contain commas themselves, a comma only starts a new option when it is
+// followed by a known option name.
+func parseFieldTag(tag string) (*fieldOptions, error) {
+	var parts []string
+	for _, p := range strings.Split(tag, ",") {
+		if len(parts) > 0 && !isFieldOption(p) {
+			parts[len(parts)-1] += "," + p
+			continue
+		}
+		parts = append(parts, p)
+	}
+
+	opts := &fieldOptions{}
+	for _, p := range parts {
+		key, val, _ := strings.Cut(p, "=")
+		var err error
+		switch strings.TrimSpace(key) {
+		case "selector":
+			opts.selector, err = Compile(val)
+		case "attr":
+			opts.attr = val
+		case "re":
+			opts.re, err = regexp.Compile(val)
+		case "format":
+			opts.format = val
+		case "header":
+			opts.header = val
+		case "required":
+			opts.required = true
+		case "":
+		default:
+			err = fmt.Errorf("unknown tag option %q", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return opts, nil
+}
+
+func isFieldOption(s string) bool {
+	key, _, _ := strings.Cut(s, "=")
+	switch strings.TrimSpace(key) {
+	case "selector", "attr", "re", "format", "header", "required":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package htmls_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Akagi201/utils-go/htmls"
+	"golang.org/x/net/html"
+)
+
+const testUnmarshalHTML = `
+<html>
+  <body>
+    <h1 data-updated="2022-03-01">Front Page</h1>
+    <table>
+      <tr class="athing">
+        <td class="title"><a href="http://a.example.com">Alpha, the first</a></td>
+        <td class="meta"><span class="score">120 points</span><span class="age">3h</span></td>
+      </tr>
+      <tr class="athing">
+        <td class="title"><a href="http://b.example.com">Beta</a></td>
+        <td class="meta"><span class="score">7 points</span><span class="age">45m</span></td>
+      </tr>
+    </table>
+  </body>
+</html>
+`
+
+type testMeta struct {
+	Score int           `htmls:"selector=span.score,re=(\\d+) points"`
+	Age   time.Duration `htmls:"selector=.age"`
+}
+
+type testArticle struct {
+	Title string     `htmls:"selector=td.title > a"`
+	URL   string     `htmls:"selector=td.title > a,attr=href"`
+	Meta  *testMeta  `htmls:"selector=td.meta"`
+	Row   *html.Node `htmls:""`
+}
+
+type testPage struct {
+	Heading  string        `htmls:"selector=h1,required"`
+	Updated  time.Time     `htmls:"selector=h1,attr=data-updated,format=2006-01-02"`
+	Articles []testArticle `htmls:"selector=tr.athing"`
+	Links    []string      `htmls:"selector=td.title a, td.missing,attr=href"`
+	Ignored  string
+}
+
+func TestUnmarshal(t *testing.T) {
+	root, _ := html.Parse(strings.NewReader(testUnmarshalHTML))
+
+	var page testPage
+	if err := htmls.Unmarshal(root, &page); err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+
+	if page.Heading != "Front Page" {
+		t.Errorf("Heading is wrong: %q", page.Heading)
+	}
+	if !page.Updated.Equal(time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Updated is wrong: %v", page.Updated)
+	}
+	if len(page.Articles) != 2 {
+		t.Fatalf("Expected 2 articles but found %d", len(page.Articles))
+	}
+	a := page.Articles[0]
+	if a.Title != "Alpha, the first" || a.URL != "http://a.example.com" {
+		t.Errorf("First article is wrong: %+v", a)
+	}
+	if a.Meta == nil || a.Meta.Score != 120 || a.Meta.Age != 3*time.Hour {
+		t.Errorf("First article meta is wrong: %+v", a.Meta)
+	}
+	if a.Row == nil || htmls.Attr(a.Row, "class") != "athing" {
+		t.Error("Expected Row to hold the tr node")
+	}
+	if page.Articles[1].Meta.Age != 45*time.Minute {
+		t.Errorf("Second article meta is wrong: %+v", page.Articles[1].Meta)
+	}
+	if strings.Join(page.Links, " ") != "http://a.example.com http://b.example.com" {
+		t.Errorf("Links are wrong: %v", page.Links)
+	}
+}
+
+func TestUnmarshalReportsFieldErrors(t *testing.T) {
+	root, _ := html.Parse(strings.NewReader(testUnmarshalHTML))
+
+	var page struct {
+		Missing string     `htmls:"selector=h2,required"`
+		Scores  []int      `htmls:"selector=span.score"`
+		Heading float64    `htmls:"selector=h1"`
+		Nested  [][]string `htmls:"selector=span.score"`
+	}
+	err := htmls.Unmarshal(root, &page)
+
+	var uerr *htmls.UnmarshalError
+	if !errors.As(err, &uerr) {
+		t.Fatal("Expected an UnmarshalError but got", err)
+	}
+	var fields []string
+	for _, fe := range uerr.Errors {
+		fields = append(fields, fe.Field)
+	}
+	if got := strings.Join(fields, " "); got != "Missing Scores[0] Scores[1] Heading Nested[0] Nested[1]" {
+		t.Errorf("Unexpected failing fields: %s", got)
+	}
+}
+
+func TestUnmarshalInvalidArguments(t *testing.T) {
+	root, _ := html.Parse(strings.NewReader(testUnmarshalHTML))
+
+	var page testPage
+	if err := htmls.Unmarshal(root, page); err == nil {
+		t.Error("Expected an error for a non-pointer")
+	}
+	var bad struct {
+		A string `htmls:"selector=div >"`
+	}
+	if err := htmls.Unmarshal(root, &bad); err == nil {
+		t.Error("Expected an error for an invalid selector")
+	}
+}
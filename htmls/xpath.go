@@ -0,0 +1,1394 @@
+package htmls
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// XPath is a compiled XPath 1.0 expression. It is safe for concurrent use.
+//
+// All axes except namespace, the core function library and the usual
+// operators are supported; variables are not. Namespace prefixes in name
+// tests are ignored. The string-value of an element is its Text, the one of
+// an attribute is its Attr.
+type XPath struct {
+	src  string
+	root xexpr
+}
+
+// XPathError is returned when an XPath expression cannot be parsed or evaluated.
+type XPathError struct {
+	Expr string
+	// Pos is the offset the parser stopped at, or -1 for evaluation errors.
+	Pos int
+	Msg string
+}
+
+func (e *XPathError) Error() string {
+	if e.Pos < 0 {
+		return fmt.Sprintf("htmls: xpath %q: %s", e.Expr, e.Msg)
+	}
+	return fmt.Sprintf("htmls: invalid xpath %q at offset %d: %s", e.Expr, e.Pos, e.Msg)
+}
+
+// CompileXPath parses an XPath 1.0 expression.
+func CompileXPath(expr string) (x *XPath, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			xe, ok := r.(*XPathError)
+			if !ok {
+				panic(r)
+			}
+			x, err = nil, xe
+		}
+	}()
+
+	p := &xparser{src: expr, toks: lexXPath(expr)}
+	root := p.parseExpr()
+	if p.peek().kind != xtEOF {
+		p.fail("unexpected %q", p.peek().text)
+	}
+	return &XPath{src: expr, root: root}, nil
+}
+
+// MustCompileXPath is like CompileXPath but panics if the expression cannot be parsed.
+func MustCompileXPath(expr string) *XPath {
+	x, err := CompileXPath(expr)
+	if err != nil {
+		panic(err)
+	}
+	return x
+}
+
+// String returns the source text of the expression.
+func (x *XPath) String() string {
+	return x.src
+}
+
+// Evaluate evaluates the expression with node as the context node. The result
+// is a []*html.Node, string, float64 or bool.
+//
+// Attribute nodes are returned as detached text nodes holding the attribute value.
+func (x *XPath) Evaluate(node *html.Node) (any, error) {
+	return evaluateAs(x, node, func(v any) any {
+		if ns, ok := v.(nodeSet); ok {
+			return ns.htmlNodes()
+		}
+		return v
+	})
+}
+
+// EvaluateNodes evaluates the expression, which must result in a node-set.
+//
+//	rows, err := htmls.MustCompileXPath("//table[@id='prices']//tr[td[2] > 100]").EvaluateNodes(root)
+func (x *XPath) EvaluateNodes(node *html.Node) ([]*html.Node, error) {
+	v, err := x.Evaluate(node)
+	if err != nil {
+		return nil, err
+	}
+	nodes, ok := v.([]*html.Node)
+	if !ok {
+		return nil, &XPathError{Expr: x.src, Pos: -1, Msg: fmt.Sprintf("result is a %s, not a node-set", xtypeName(v))}
+	}
+	return nodes, nil
+}
+
+// EvaluateStrings evaluates the expression, which must result in a node-set,
+// and returns the string-value of each node.
+func (x *XPath) EvaluateStrings(node *html.Node) ([]string, error) {
+	nodes, err := x.EvaluateNodes(node)
+	if err != nil {
+		return nil, err
+	}
+	s := make([]string, len(nodes))
+	for i, n := range nodes {
+		s[i] = stringValue(xnode{n: n})
+	}
+	return s, nil
+}
+
+// EvaluateString evaluates the expression and converts the result as the string() function does.
+func (x *XPath) EvaluateString(node *html.Node) (string, error) {
+	return evaluateAs(x, node, xstring)
+}
+
+// EvaluateNumber evaluates the expression and converts the result as the number() function does.
+func (x *XPath) EvaluateNumber(node *html.Node) (float64, error) {
+	return evaluateAs(x, node, xnumber)
+}
+
+// EvaluateBool evaluates the expression and converts the result as the boolean() function does.
+func (x *XPath) EvaluateBool(node *html.Node) (bool, error) {
+	return evaluateAs(x, node, xbool)
+}
+
+func evaluateAs[T any](x *XPath, node *html.Node, conv func(any) T) (t T, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			msg, ok := r.(xpathPanic)
+			if !ok {
+				panic(r)
+			}
+			err = &XPathError{Expr: x.src, Pos: -1, Msg: string(msg)}
+		}
+	}()
+	c := &xcontext{node: xnode{n: node}, pos: 1, size: 1, order: &docOrder{}}
+	return conv(x.root.eval(c)), nil
+}
+
+// XPathNodes compiles and evaluates an expression resulting in a node-set.
+func XPathNodes(node *html.Node, expr string) ([]*html.Node, error) {
+	x, err := CompileXPath(expr)
+	if err != nil {
+		return nil, err
+	}
+	return x.EvaluateNodes(node)
+}
+
+// XPathString compiles and evaluates an expression, converting the result to a string.
+func XPathString(node *html.Node, expr string) (string, error) {
+	x, err := CompileXPath(expr)
+	if err != nil {
+		return "", err
+	}
+	return x.EvaluateString(node)
+}
+
+// XPathNumber compiles and evaluates an expression, converting the result to a number.
+func XPathNumber(node *html.Node, expr string) (float64, error) {
+	x, err := CompileXPath(expr)
+	if err != nil {
+		return 0, err
+	}
+	return x.EvaluateNumber(node)
+}
+
+// xpathPanic carries evaluation errors up to Evaluate.
+type xpathPanic string
+
+func xfail(format string, args ...any) {
+	panic(xpathPanic(fmt.Sprintf(format, args...)))
+}
+
+// xnode is a node in the XPath data model. html.Node has no attribute nodes,
+// so those are represented by their element and a 1-based index into Attr.
+type xnode struct {
+	n    *html.Node
+	attr int
+}
+
+type nodeSet []xnode
+
+func (ns nodeSet) htmlNodes() []*html.Node {
+	nodes := make([]*html.Node, len(ns))
+	for i, x := range ns {
+		if x.attr > 0 {
+			nodes[i] = &html.Node{Type: html.TextNode, Data: x.n.Attr[x.attr-1].Val}
+		} else {
+			nodes[i] = x.n
+		}
+	}
+	return nodes
+}
+
+func stringValue(x xnode) string {
+	if x.attr > 0 {
+		return x.n.Attr[x.attr-1].Val
+	}
+	switch x.n.Type {
+	case html.TextNode, html.CommentNode:
+		return x.n.Data
+	}
+	return Text(x.n)
+}
+
+func rootOf(n *html.Node) *html.Node {
+	for n.Parent != nil {
+		n = n.Parent
+	}
+	return n
+}
+
+// docOrder sorts node-sets into document order, numbering the tree on first use.
+type docOrder struct {
+	idx map[*html.Node]int
+}
+
+func (o *docOrder) sort(ns nodeSet) nodeSet {
+	if len(ns) < 2 {
+		return ns
+	}
+	if o.idx == nil {
+		o.idx = map[*html.Node]int{}
+		i := 0
+		var walk func(*html.Node)
+		walk = func(n *html.Node) {
+			o.idx[n] = i
+			i++
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				walk(c)
+			}
+		}
+		walk(rootOf(ns[0].n))
+	}
+
+	seen := make(map[xnode]bool, len(ns))
+	out := ns[:0:0]
+	for _, x := range ns {
+		if !seen[x] {
+			seen[x] = true
+			out = append(out, x)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.n != b.n {
+			return o.idx[a.n] < o.idx[b.n]
+		}
+		return a.attr < b.attr
+	})
+	return out
+}
+
+type xcontext struct {
+	node      xnode
+	pos, size int
+	order     *docOrder
+}
+
+var xpathNumberRe = regexp.MustCompile(`^-?(\d+(\.\d*)?|\.\d+)$`)
+
+func xstring(v any) string {
+	switch v := v.(type) {
+	case nodeSet:
+		if len(v) == 0 {
+			return ""
+		}
+		return stringValue(v[0])
+	case string:
+		return v
+	case float64:
+		return formatXNumber(v)
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	}
+	panic("unreachable")
+}
+
+func xnumber(v any) float64 {
+	switch v := v.(type) {
+	case float64:
+		return v
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	}
+	s := strings.Trim(xstring(v), " \t\r\n")
+	if !xpathNumberRe.MatchString(s) {
+		return math.NaN()
+	}
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
+
+func xbool(v any) bool {
+	switch v := v.(type) {
+	case nodeSet:
+		return len(v) > 0
+	case string:
+		return v != ""
+	case float64:
+		return v != 0 && !math.IsNaN(v)
+	case bool:
+		return v
+	}
+	panic("unreachable")
+}
+
+func xnodes(v any, fn string) nodeSet {
+	ns, ok := v.(nodeSet)
+	if !ok {
+		xfail("%s expects a node-set, got a %s", fn, xtypeName(v))
+	}
+	return ns
+}
+
+func xtypeName(v any) string {
+	switch v.(type) {
+	case nodeSet, []*html.Node:
+		return "node-set"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	}
+	return "boolean"
+}
+
+func formatXNumber(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	case f == 0:
+		return "0"
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+type xexpr interface {
+	eval(c *xcontext) any
+}
+
+type xliteral string
+
+func (e xliteral) eval(*xcontext) any { return string(e) }
+
+type xnumberLit float64
+
+func (e xnumberLit) eval(*xcontext) any { return float64(e) }
+
+type xlogic struct {
+	and  bool
+	l, r xexpr
+}
+
+func (e *xlogic) eval(c *xcontext) any {
+	l := xbool(e.l.eval(c))
+	if l != e.and {
+		return l
+	}
+	return xbool(e.r.eval(c))
+}
+
+type xcompare struct {
+	op   string
+	l, r xexpr
+}
+
+func (e *xcompare) eval(c *xcontext) any {
+	l, r := e.l.eval(c), e.r.eval(c)
+	ln, lok := l.(nodeSet)
+	rn, rok := r.(nodeSet)
+	switch {
+	case lok && rok:
+		for _, a := range ln {
+			for _, b := range rn {
+				if compareAtoms(e.op, stringValue(a), stringValue(b)) {
+					return true
+				}
+			}
+		}
+		return false
+	case lok:
+		if _, ok := r.(bool); ok {
+			return compareAtoms(e.op, xbool(ln), r)
+		}
+		for _, a := range ln {
+			if compareAtoms(e.op, stringValue(a), r) {
+				return true
+			}
+		}
+		return false
+	case rok:
+		if _, ok := l.(bool); ok {
+			return compareAtoms(e.op, l, xbool(rn))
+		}
+		for _, b := range rn {
+			if compareAtoms(e.op, l, stringValue(b)) {
+				return true
+			}
+		}
+		return false
+	}
+	return compareAtoms(e.op, l, r)
+}
+
+func compareAtoms(op string, l, r any) bool {
+	if op == "=" || op == "!=" {
+		var eq bool
+		_, lb := l.(bool)
+		_, rb := r.(bool)
+		_, lf := l.(float64)
+		_, rf := r.(float64)
+		switch {
+		case lb || rb:
+			eq = xbool(l) == xbool(r)
+		case lf || rf:
+			eq = xnumber(l) == xnumber(r)
+		default:
+			eq = xstring(l) == xstring(r)
+		}
+		return eq == (op == "=")
+	}
+
+	a, b := xnumber(l), xnumber(r)
+	switch op {
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	}
+	return a >= b
+}
+
+type xarith struct {
+	op   string
+	l, r xexpr
+}
+
+func (e *xarith) eval(c *xcontext) any {
+	a, b := xnumber(e.l.eval(c)), xnumber(e.r.eval(c))
+	switch e.op {
+	case "+":
+		return a + b
+	case "-":
+		return a - b
+	case "*":
+		return a * b
+	case "div":
+		return a / b
+	}
+	return math.Mod(a, b)
+}
+
+type xneg struct {
+	e xexpr
+}
+
+func (e *xneg) eval(c *xcontext) any {
+	return -xnumber(e.e.eval(c))
+}
+
+type xunion struct {
+	l, r xexpr
+}
+
+func (e *xunion) eval(c *xcontext) any {
+	l := xnodes(e.l.eval(c), "|")
+	r := xnodes(e.r.eval(c), "|")
+	return c.order.sort(append(append(nodeSet{}, l...), r...))
+}
+
+type xfilter struct {
+	primary xexpr
+	preds   []xexpr
+}
+
+func (e *xfilter) eval(c *xcontext) any {
+	v := e.primary.eval(c)
+	if len(e.preds) == 0 {
+		return v
+	}
+	ns := xnodes(v, "predicate")
+	for _, pred := range e.preds {
+		ns = applyPredicate(ns, pred, c.order)
+	}
+	return ns
+}
+
+type xlocationPath struct {
+	// filter is the expression the path starts from, nil for plain location paths.
+	filter xexpr
+	abs    bool
+	steps  []*xstep
+}
+
+func (e *xlocationPath) eval(c *xcontext) any {
+	var ns nodeSet
+	switch {
+	case e.filter != nil:
+		ns = xnodes(e.filter.eval(c), "/")
+	case e.abs:
+		ns = nodeSet{{n: rootOf(c.node.n)}}
+	default:
+		ns = nodeSet{c.node}
+	}
+	for _, s := range e.steps {
+		ns = s.apply(ns, c.order)
+	}
+	return ns
+}
+
+type xstep struct {
+	axis  xaxis
+	test  xnodeTest
+	preds []xexpr
+}
+
+func (s *xstep) apply(in nodeSet, order *docOrder) nodeSet {
+	var out nodeSet
+	for _, x := range in {
+		var cand nodeSet
+		for _, y := range s.axis.nodes(x) {
+			if s.test.match(y, s.axis == axisAttribute) {
+				cand = append(cand, y)
+			}
+		}
+		for _, pred := range s.preds {
+			cand = applyPredicate(cand, pred, order)
+		}
+		out = append(out, cand...)
+	}
+	return order.sort(out)
+}
+
+func applyPredicate(ns nodeSet, pred xexpr, order *docOrder) nodeSet {
+	var out nodeSet
+	for i, x := range ns {
+		c := &xcontext{node: x, pos: i + 1, size: len(ns), order: order}
+		v := pred.eval(c)
+		if f, ok := v.(float64); ok {
+			if f == float64(i+1) {
+				out = append(out, x)
+			}
+		} else if xbool(v) {
+			out = append(out, x)
+		}
+	}
+	return out
+}
+
+type xcall struct {
+	fn   *xfunc
+	args []xexpr
+}
+
+func (e *xcall) eval(c *xcontext) any {
+	args := make([]any, len(e.args))
+	for i, a := range e.args {
+		args[i] = a.eval(c)
+	}
+	return e.fn.call(c, args)
+}
+
+type xaxis int
+
+const (
+	axisChild xaxis = iota
+	axisDescendant
+	axisDescendantOrSelf
+	axisParent
+	axisAncestor
+	axisAncestorOrSelf
+	axisFollowingSibling
+	axisPrecedingSibling
+	axisFollowing
+	axisPreceding
+	axisAttribute
+	axisSelf
+)
+
+var xaxes = map[string]xaxis{
+	"child":              axisChild,
+	"descendant":         axisDescendant,
+	"descendant-or-self": axisDescendantOrSelf,
+	"parent":             axisParent,
+	"ancestor":           axisAncestor,
+	"ancestor-or-self":   axisAncestorOrSelf,
+	"following-sibling":  axisFollowingSibling,
+	"preceding-sibling":  axisPrecedingSibling,
+	"following":          axisFollowing,
+	"preceding":          axisPreceding,
+	"attribute":          axisAttribute,
+	"self":               axisSelf,
+}
+
+// nodes returns the nodes on the axis in axis order, i.e. reverse
+// document order for the reverse axes.
+func (a xaxis) nodes(x xnode) nodeSet {
+	var ns nodeSet
+	isAttr := x.attr > 0
+	switch a {
+	case axisSelf:
+		ns = nodeSet{x}
+	case axisChild:
+		if !isAttr {
+			for c := x.n.FirstChild; c != nil; c = c.NextSibling {
+				ns = append(ns, xnode{n: c})
+			}
+		}
+	case axisDescendant, axisDescendantOrSelf:
+		if a == axisDescendantOrSelf {
+			ns = nodeSet{x}
+		}
+		if !isAttr {
+			ns = appendDescendants(ns, x.n)
+		}
+	case axisParent:
+		if isAttr {
+			ns = nodeSet{{n: x.n}}
+		} else if x.n.Parent != nil {
+			ns = nodeSet{{n: x.n.Parent}}
+		}
+	case axisAncestor, axisAncestorOrSelf:
+		if a == axisAncestorOrSelf {
+			ns = nodeSet{x}
+		}
+		p := x.n.Parent
+		if isAttr {
+			p = x.n
+		}
+		for ; p != nil; p = p.Parent {
+			ns = append(ns, xnode{n: p})
+		}
+	case axisFollowingSibling:
+		if !isAttr {
+			for s := x.n.NextSibling; s != nil; s = s.NextSibling {
+				ns = append(ns, xnode{n: s})
+			}
+		}
+	case axisPrecedingSibling:
+		if !isAttr {
+			for s := x.n.PrevSibling; s != nil; s = s.PrevSibling {
+				ns = append(ns, xnode{n: s})
+			}
+		}
+	case axisFollowing:
+		if isAttr {
+			ns = appendDescendants(ns, x.n)
+		}
+		for p := x.n; p != nil; p = p.Parent {
+			for s := p.NextSibling; s != nil; s = s.NextSibling {
+				ns = append(ns, xnode{n: s})
+				ns = appendDescendants(ns, s)
+			}
+		}
+	case axisPreceding:
+		for p := x.n; p != nil; p = p.Parent {
+			for s := p.PrevSibling; s != nil; s = s.PrevSibling {
+				sub := appendDescendants(nodeSet{{n: s}}, s)
+				for i := len(sub) - 1; i >= 0; i-- {
+					ns = append(ns, sub[i])
+				}
+			}
+		}
+	case axisAttribute:
+		if !isAttr && x.n.Type == html.ElementNode {
+			for i := range x.n.Attr {
+				ns = append(ns, xnode{n: x.n, attr: i + 1})
+			}
+		}
+	}
+	return ns
+}
+
+func appendDescendants(ns nodeSet, n *html.Node) nodeSet {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		ns = append(ns, xnode{n: c})
+		ns = appendDescendants(ns, c)
+	}
+	return ns
+}
+
+type xnodeTestKind int
+
+const (
+	testName xnodeTestKind = iota
+	testAnyName
+	testNode
+	testText
+	testComment
+	testPI
+)
+
+type xnodeTest struct {
+	kind xnodeTestKind
+	name string
+}
+
+func (t xnodeTest) match(x xnode, attrAxis bool) bool {
+	switch t.kind {
+	case testNode:
+		return true
+	case testText:
+		return x.attr == 0 && x.n.Type == html.TextNode
+	case testComment:
+		return x.attr == 0 && x.n.Type == html.CommentNode
+	case testPI:
+		return false
+	}
+
+	// name tests only match the principal node type of the axis
+	if attrAxis {
+		return x.attr > 0 && (t.kind == testAnyName || x.n.Attr[x.attr-1].Key == t.name)
+	}
+	if x.attr > 0 || x.n.Type != html.ElementNode {
+		return false
+	}
+	return t.kind == testAnyName || strings.EqualFold(x.n.Data, t.name)
+}
+
+type xfunc struct {
+	minArgs, maxArgs int // maxArgs < 0 means unlimited
+	call             func(c *xcontext, args []any) any
+}
+
+// contextOr returns the only argument, or the context node as a node-set.
+func contextOr(c *xcontext, args []any) any {
+	if len(args) > 0 {
+		return args[0]
+	}
+	return nodeSet{c.node}
+}
+
+func nodeName(c *xcontext, args []any) any {
+	ns := xnodes(contextOr(c, args), "name")
+	if len(ns) == 0 {
+		return ""
+	}
+	x := ns[0]
+	if x.attr > 0 {
+		return x.n.Attr[x.attr-1].Key
+	}
+	if x.n.Type == html.ElementNode {
+		return x.n.Data
+	}
+	return ""
+}
+
+func xround(f float64) float64 {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return f
+	}
+	return math.Floor(f + 0.5)
+}
+
+var xfuncs = map[string]*xfunc{
+	"last": {0, 0, func(c *xcontext, _ []any) any {
+		return float64(c.size)
+	}},
+	"position": {0, 0, func(c *xcontext, _ []any) any {
+		return float64(c.pos)
+	}},
+	"count": {1, 1, func(_ *xcontext, args []any) any {
+		return float64(len(xnodes(args[0], "count")))
+	}},
+	"id": {1, 1, func(c *xcontext, args []any) any {
+		var ids []string
+		if ns, ok := args[0].(nodeSet); ok {
+			for _, x := range ns {
+				ids = append(ids, strings.Fields(stringValue(x))...)
+			}
+		} else {
+			ids = strings.Fields(xstring(args[0]))
+		}
+		want := map[string]bool{}
+		for _, id := range ids {
+			want[id] = true
+		}
+		var ns nodeSet
+		for _, n := range FindAllNested(rootOf(c.node.n), func(n *html.Node) bool {
+			return n.Type == html.ElementNode && want[Attr(n, "id")]
+		}) {
+			ns = append(ns, xnode{n: n})
+		}
+		return ns
+	}},
+	"local-name": {0, 1, nodeName},
+	"name":       {0, 1, nodeName},
+	"string": {0, 1, func(c *xcontext, args []any) any {
+		return xstring(contextOr(c, args))
+	}},
+	"concat": {2, -1, func(_ *xcontext, args []any) any {
+		var sb strings.Builder
+		for _, a := range args {
+			sb.WriteString(xstring(a))
+		}
+		return sb.String()
+	}},
+	"starts-with": {2, 2, func(_ *xcontext, args []any) any {
+		return strings.HasPrefix(xstring(args[0]), xstring(args[1]))
+	}},
+	"contains": {2, 2, func(_ *xcontext, args []any) any {
+		return strings.Contains(xstring(args[0]), xstring(args[1]))
+	}},
+	"substring-before": {2, 2, func(_ *xcontext, args []any) any {
+		s, sep := xstring(args[0]), xstring(args[1])
+		if i := strings.Index(s, sep); i >= 0 {
+			return s[:i]
+		}
+		return ""
+	}},
+	"substring-after": {2, 2, func(_ *xcontext, args []any) any {
+		s, sep := xstring(args[0]), xstring(args[1])
+		if i := strings.Index(s, sep); i >= 0 {
+			return s[i+len(sep):]
+		}
+		return ""
+	}},
+	"substring": {2, 3, func(_ *xcontext, args []any) any {
+		s := xstring(args[0])
+		start := xround(xnumber(args[1]))
+		end := math.Inf(1)
+		if len(args) == 3 {
+			end = start + xround(xnumber(args[2]))
+		}
+		var sb strings.Builder
+		p := 1.0
+		for _, r := range s {
+			if p >= start && p < end {
+				sb.WriteRune(r)
+			}
+			p++
+		}
+		return sb.String()
+	}},
+	"string-length": {0, 1, func(c *xcontext, args []any) any {
+		return float64(utf8.RuneCountInString(xstring(contextOr(c, args))))
+	}},
+	"normalize-space": {0, 1, func(c *xcontext, args []any) any {
+		return strings.Join(strings.Fields(xstring(contextOr(c, args))), " ")
+	}},
+	"translate": {3, 3, func(_ *xcontext, args []any) any {
+		from, to := []rune(xstring(args[1])), []rune(xstring(args[2]))
+		m := map[rune]rune{}
+		for i, r := range from {
+			if _, ok := m[r]; ok {
+				continue
+			}
+			if i < len(to) {
+				m[r] = to[i]
+			} else {
+				m[r] = -1
+			}
+		}
+		return strings.Map(func(r rune) rune {
+			if t, ok := m[r]; ok {
+				return t
+			}
+			return r
+		}, xstring(args[0]))
+	}},
+	"boolean": {1, 1, func(_ *xcontext, args []any) any {
+		return xbool(args[0])
+	}},
+	"not": {1, 1, func(_ *xcontext, args []any) any {
+		return !xbool(args[0])
+	}},
+	"true": {0, 0, func(*xcontext, []any) any {
+		return true
+	}},
+	"false": {0, 0, func(*xcontext, []any) any {
+		return false
+	}},
+	"lang": {1, 1, func(c *xcontext, args []any) any {
+		want := strings.ToLower(xstring(args[0]))
+		for n := c.node.n; n != nil; n = n.Parent {
+			if lang, ok := attr(n, "lang"); ok {
+				lang = strings.ToLower(lang)
+				return lang == want || strings.HasPrefix(lang, want+"-")
+			}
+		}
+		return false
+	}},
+	"number": {0, 1, func(c *xcontext, args []any) any {
+		return xnumber(contextOr(c, args))
+	}},
+	"sum": {1, 1, func(_ *xcontext, args []any) any {
+		sum := 0.0
+		for _, x := range xnodes(args[0], "sum") {
+			sum += xnumber(stringValue(x))
+		}
+		return sum
+	}},
+	"floor": {1, 1, func(_ *xcontext, args []any) any {
+		return math.Floor(xnumber(args[0]))
+	}},
+	"ceiling": {1, 1, func(_ *xcontext, args []any) any {
+		return math.Ceil(xnumber(args[0]))
+	}},
+	"round": {1, 1, func(_ *xcontext, args []any) any {
+		return xround(xnumber(args[0]))
+	}},
+}
+
+type xtokenKind int
+
+const (
+	xtEOF xtokenKind = iota
+	xtLParen
+	xtRParen
+	xtLBracket
+	xtRBracket
+	xtDot
+	xtDotDot
+	xtAt
+	xtComma
+	xtColonColon
+	xtDollar
+	xtLiteral
+	xtNumber
+	xtName     // a name test: NCName, QName, prefix:* or *
+	xtNodeType // comment, text, processing-instruction or node followed by (
+	xtFunc     // a function name followed by (
+	xtAxis     // an axis name followed by ::
+	xtOperator // and, or, mod, div, *, /, //, |, +, -, =, !=, <, <=, >, >=
+)
+
+type xtoken struct {
+	kind xtokenKind
+	text string
+	pos  int
+}
+
+func lexXPath(src string) []xtoken {
+	var toks []xtoken
+	fail := func(pos int, format string, args ...any) {
+		panic(&XPathError{Expr: src, Pos: pos, Msg: fmt.Sprintf(format, args...)})
+	}
+	emit := func(kind xtokenKind, start, end int) {
+		toks = append(toks, xtoken{kind: kind, text: src[start:end], pos: start})
+	}
+
+	i := 0
+	for {
+		for i < len(src) && isSpace(src[i]) {
+			i++
+		}
+		if i >= len(src) {
+			toks = append(toks, xtoken{kind: xtEOF, pos: i})
+			return toks
+		}
+
+		// A * is a multiplication and a name is an operator name unless
+		// there is no preceding token or it starts an operand.
+		operatorContext := false
+		if len(toks) > 0 {
+			switch toks[len(toks)-1].kind {
+			case xtAt, xtColonColon, xtLParen, xtLBracket, xtComma, xtOperator:
+			default:
+				operatorContext = true
+			}
+		}
+
+		start := i
+		c := src[i]
+		switch {
+		case c == '(':
+			i++
+			emit(xtLParen, start, i)
+		case c == ')':
+			i++
+			emit(xtRParen, start, i)
+		case c == '[':
+			i++
+			emit(xtLBracket, start, i)
+		case c == ']':
+			i++
+			emit(xtRBracket, start, i)
+		case c == '@':
+			i++
+			emit(xtAt, start, i)
+		case c == ',':
+			i++
+			emit(xtComma, start, i)
+		case c == '$':
+			i++
+			emit(xtDollar, start, i)
+		case c == ':':
+			if !strings.HasPrefix(src[i:], "::") {
+				fail(i, "unexpected ':'")
+			}
+			i += 2
+			emit(xtColonColon, start, i)
+		case c == '.' && strings.HasPrefix(src[i:], ".."):
+			i += 2
+			emit(xtDotDot, start, i)
+		case c == '.' && (i+1 >= len(src) || !isDigit(src[i+1])):
+			i++
+			emit(xtDot, start, i)
+		case c == '.' || isDigit(c):
+			for i < len(src) && isDigit(src[i]) {
+				i++
+			}
+			if i < len(src) && src[i] == '.' {
+				i++
+				for i < len(src) && isDigit(src[i]) {
+					i++
+				}
+			}
+			emit(xtNumber, start, i)
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(src[i+1:], c)
+			if end < 0 {
+				fail(i, "unterminated literal")
+			}
+			i += end + 2
+			toks = append(toks, xtoken{kind: xtLiteral, text: src[start+1 : i-1], pos: start})
+		case c == '/' || c == '|' || c == '+' || c == '-' || c == '=':
+			i++
+			if c == '/' && i < len(src) && src[i] == '/' {
+				i++
+			}
+			emit(xtOperator, start, i)
+		case c == '!' || c == '<' || c == '>':
+			i++
+			if i < len(src) && src[i] == '=' {
+				i++
+			} else if c == '!' {
+				fail(start, "unexpected '!'")
+			}
+			emit(xtOperator, start, i)
+		case c == '*':
+			i++
+			if operatorContext {
+				emit(xtOperator, start, i)
+			} else {
+				emit(xtName, start, i)
+			}
+		case isXNameStart(c):
+			i = scanNCName(src, i)
+			if operatorContext {
+				switch src[start:i] {
+				case "and", "or", "mod", "div":
+					emit(xtOperator, start, i)
+				default:
+					fail(start, "unexpected %q", src[start:i])
+				}
+				continue
+			}
+			name := src[start:i]
+			if i+1 < len(src) && src[i] == ':' && src[i+1] != ':' {
+				switch {
+				case src[i+1] == '*':
+					i += 2
+				case isXNameStart(src[i+1]):
+					i = scanNCName(src, i+1)
+				default:
+					fail(i, "invalid qualified name")
+				}
+			}
+			j := i
+			for j < len(src) && isSpace(src[j]) {
+				j++
+			}
+			switch {
+			case j < len(src) && src[j] == '(' && i-start == len(name):
+				switch name {
+				case "comment", "text", "processing-instruction", "node":
+					emit(xtNodeType, start, i)
+				default:
+					emit(xtFunc, start, i)
+				}
+			case strings.HasPrefix(src[j:], "::") && i-start == len(name):
+				emit(xtAxis, start, i)
+			default:
+				emit(xtName, start, i)
+			}
+		default:
+			fail(i, "unexpected %q", c)
+		}
+	}
+}
+
+func scanNCName(src string, i int) int {
+	for i < len(src) && (isXNameStart(src[i]) || isDigit(src[i]) || src[i] == '-' || src[i] == '.') {
+		i++
+	}
+	return i
+}
+
+func isXNameStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c >= 0x80
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+type xparser struct {
+	src  string
+	toks []xtoken
+	i    int
+}
+
+func (p *xparser) peek() xtoken {
+	return p.toks[p.i]
+}
+
+func (p *xparser) next() xtoken {
+	t := p.toks[p.i]
+	if t.kind != xtEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *xparser) isOp(ops ...string) bool {
+	t := p.peek()
+	if t.kind != xtOperator {
+		return false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *xparser) expect(kind xtokenKind, what string) {
+	if p.peek().kind != kind {
+		p.fail("expected %s", what)
+	}
+	p.next()
+}
+
+func (p *xparser) fail(format string, args ...any) {
+	panic(&XPathError{Expr: p.src, Pos: p.peek().pos, Msg: fmt.Sprintf(format, args...)})
+}
+
+func (p *xparser) parseExpr() xexpr {
+	return p.parseOr()
+}
+
+func (p *xparser) parseOr() xexpr {
+	e := p.parseAnd()
+	for p.isOp("or") {
+		p.next()
+		e = &xlogic{and: false, l: e, r: p.parseAnd()}
+	}
+	return e
+}
+
+func (p *xparser) parseAnd() xexpr {
+	e := p.parseEquality()
+	for p.isOp("and") {
+		p.next()
+		e = &xlogic{and: true, l: e, r: p.parseEquality()}
+	}
+	return e
+}
+
+func (p *xparser) parseEquality() xexpr {
+	e := p.parseRelational()
+	for p.isOp("=", "!=") {
+		op := p.next().text
+		e = &xcompare{op: op, l: e, r: p.parseRelational()}
+	}
+	return e
+}
+
+func (p *xparser) parseRelational() xexpr {
+	e := p.parseAdditive()
+	for p.isOp("<", "<=", ">", ">=") {
+		op := p.next().text
+		e = &xcompare{op: op, l: e, r: p.parseAdditive()}
+	}
+	return e
+}
+
+func (p *xparser) parseAdditive() xexpr {
+	e := p.parseMultiplicative()
+	for p.isOp("+", "-") {
+		op := p.next().text
+		e = &xarith{op: op, l: e, r: p.parseMultiplicative()}
+	}
+	return e
+}
+
+func (p *xparser) parseMultiplicative() xexpr {
+	e := p.parseUnary()
+	for p.isOp("*", "div", "mod") {
+		op := p.next().text
+		e = &xarith{op: op, l: e, r: p.parseUnary()}
+	}
+	return e
+}
+
+func (p *xparser) parseUnary() xexpr {
+	if p.isOp("-") {
+		p.next()
+		return &xneg{e: p.parseUnary()}
+	}
+	return p.parseUnion()
+}
+
+func (p *xparser) parseUnion() xexpr {
+	e := p.parsePath()
+	for p.isOp("|") {
+		p.next()
+		e = &xunion{l: e, r: p.parsePath()}
+	}
+	return e
+}
+
+func (p *xparser) parsePath() xexpr {
+	switch p.peek().kind {
+	case xtLiteral, xtNumber, xtFunc, xtLParen, xtDollar:
+		e := &xfilter{primary: p.parsePrimary()}
+		for p.peek().kind == xtLBracket {
+			e.preds = append(e.preds, p.parsePredicate())
+		}
+		if !p.isOp("/", "//") {
+			return e
+		}
+		return &xlocationPath{filter: e, steps: p.parseRelativePath(p.next().text == "//")}
+	}
+
+	switch {
+	case p.isOp("/"):
+		p.next()
+		path := &xlocationPath{abs: true}
+		switch p.peek().kind {
+		case xtName, xtAxis, xtAt, xtDot, xtDotDot, xtNodeType:
+			path.steps = p.parseRelativePath(false)
+		}
+		return path
+	case p.isOp("//"):
+		p.next()
+		return &xlocationPath{abs: true, steps: p.parseRelativePath(true)}
+	}
+	return &xlocationPath{steps: p.parseRelativePath(false)}
+}
+
+// parseRelativePath parses steps separated by / or //, the latter being short
+// for /descendant-or-self::node()/. When descendant is set the path was
+// preceded by a //.
+func (p *xparser) parseRelativePath(descendant bool) []*xstep {
+	var steps []*xstep
+	for {
+		if descendant {
+			steps = append(steps, &xstep{axis: axisDescendantOrSelf, test: xnodeTest{kind: testNode}})
+		}
+		steps = append(steps, p.parseStep())
+		if !p.isOp("/", "//") {
+			return steps
+		}
+		descendant = p.next().text == "//"
+	}
+}
+
+func (p *xparser) parseStep() *xstep {
+	switch p.peek().kind {
+	case xtDot:
+		p.next()
+		return &xstep{axis: axisSelf, test: xnodeTest{kind: testNode}}
+	case xtDotDot:
+		p.next()
+		return &xstep{axis: axisParent, test: xnodeTest{kind: testNode}}
+	}
+
+	s := &xstep{axis: axisChild}
+	switch t := p.peek(); t.kind {
+	case xtAt:
+		p.next()
+		s.axis = axisAttribute
+	case xtAxis:
+		axis, ok := xaxes[t.text]
+		if !ok {
+			p.fail("unsupported axis %q", t.text)
+		}
+		p.next()
+		p.expect(xtColonColon, "'::'")
+		s.axis = axis
+	}
+
+	switch t := p.peek(); t.kind {
+	case xtName:
+		p.next()
+		name := t.text
+		if i := strings.IndexByte(name, ':'); i >= 0 {
+			name = name[i+1:]
+		}
+		if name == "*" {
+			s.test = xnodeTest{kind: testAnyName}
+		} else {
+			s.test = xnodeTest{kind: testName, name: strings.ToLower(name)}
+		}
+	case xtNodeType:
+		p.next()
+		p.expect(xtLParen, "'('")
+		switch t.text {
+		case "node":
+			s.test.kind = testNode
+		case "text":
+			s.test.kind = testText
+		case "comment":
+			s.test.kind = testComment
+		default:
+			s.test.kind = testPI
+			if p.peek().kind == xtLiteral {
+				p.next()
+			}
+		}
+		p.expect(xtRParen, "')'")
+	default:
+		p.fail("expected a node test")
+	}
+
+	for p.peek().kind == xtLBracket {
+		s.preds = append(s.preds, p.parsePredicate())
+	}
+	return s
+}
+
+func (p *xparser) parsePredicate() xexpr {
+	p.expect(xtLBracket, "'['")
+	e := p.parseExpr()
+	p.expect(xtRBracket, "']'")
+	return e
+}
+
+func (p *xparser) parsePrimary() xexpr {
+	switch t := p.peek(); t.kind {
+	case xtLiteral:
+		p.next()
+		return xliteral(t.text)
+	case xtNumber:
+		p.next()
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			p.fail("invalid number %q", t.text)
+		}
+		return xnumberLit(f)
+	case xtLParen:
+		p.next()
+		e := p.parseExpr()
+		p.expect(xtRParen, "')'")
+		return e
+	case xtDollar:
+		p.fail("variables are not supported")
+	}
+
+	t := p.next()
+	fn, ok := xfuncs[t.text]
+	if !ok {
+		p.i--
+		p.fail("unknown function %s()", t.text)
+	}
+	p.expect(xtLParen, "'('")
+	var args []xexpr
+	if p.peek().kind != xtRParen {
+		for {
+			args = append(args, p.parseExpr())
+			if p.peek().kind != xtComma {
+				break
+			}
+			p.next()
+		}
+	}
+	if len(args) < fn.minArgs || fn.maxArgs >= 0 && len(args) > fn.maxArgs {
+		p.fail("wrong number of arguments to %s()", t.text)
+	}
+	p.expect(xtRParen, "')'")
+	return &xcall{fn: fn, args: args}
+}
@@ -0,0 +1,174 @@
+package htmls_test
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/Akagi201/utils-go/htmls"
+	"golang.org/x/net/html"
+)
+
+const testXPathHTML = `
+<html>
+  <body>
+    <table id="prices">
+      <tr><th>Item</th><th>Price</th></tr>
+      <tr class="row"><td>Apple</td><td>120</td></tr>
+      <tr class="row"><td>Pear</td><td>80</td></tr>
+      <tr class="row"><td>Plum</td><td>150</td></tr>
+    </table>
+    <ul lang="en-GB">
+      <li><a href="/a">first</a></li>
+      <li><a href="/b" rel="nofollow">second</a></li>
+      <li>third</li>
+    </ul>
+    <!-- a comment -->
+  </body>
+</html>
+`
+
+func parseXPathHTML(t *testing.T) *html.Node {
+	t.Helper()
+	root, err := html.Parse(strings.NewReader(testXPathHTML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestXPathNodes(t *testing.T) {
+	root := parseXPathHTML(t)
+
+	cases := []struct {
+		expr string
+		want string
+	}{
+		{"//tr[@class='row']/td[1]", "Apple|Pear|Plum"},
+		{"//tr[td[2] > 100]/td[1]", "Apple|Plum"},
+		{"//table[@id='prices']//tr[last()]/td[1]", "Plum"},
+		{"//tr[position() > 1 and position() < 4]/td[1]", "Apple|Pear"},
+		{"//td[text()='Pear']/following-sibling::td", "80"},
+		{"//td[.='Pear']/parent::tr/preceding-sibling::tr[1]/td[1]", "Apple"},
+		{"//td[.='Plum']/ancestor::table/@id", "prices"},
+		{"//li[a]/a/@href", "/a|/b"},
+		{"//a[@rel]/text()", "second"},
+		{"//li[not(a)]", "third"},
+		{"//li[contains(., 'ir')]", "first|third"},
+		{"(//li)[2]", "second"},
+		{"//li[2] | //li[1]", "first|second"},
+		{"//ul/li[starts-with(normalize-space(.), 's')]", "second"},
+		{"//th[1]/following::td[1]", "Apple"},
+		{"//a[.='second']/preceding::a", "first"},
+		{"//tr[2]/*[self::td][2]", "120"},
+		{"//body/comment()", " a comment "},
+		{"//li[lang('en')][1]", "first"},
+		{"id('prices')//th", "Item|Price"},
+	}
+	for _, c := range cases {
+		x, err := htmls.CompileXPath(c.expr)
+		if err != nil {
+			t.Errorf("CompileXPath(%q) returned error: %v", c.expr, err)
+			continue
+		}
+		got, err := x.EvaluateStrings(root)
+		if err != nil {
+			t.Errorf("%q returned error: %v", c.expr, err)
+			continue
+		}
+		if strings.Join(got, "|") != c.want {
+			t.Errorf("%q = %q, want %q", c.expr, strings.Join(got, "|"), c.want)
+		}
+	}
+}
+
+func TestXPathValues(t *testing.T) {
+	root := parseXPathHTML(t)
+
+	numbers := []struct {
+		expr string
+		want float64
+	}{
+		{"count(//tr)", 4},
+		{"sum(//tr/td[2])", 350},
+		{"1 + 2 * 3 - 4 div 2", 5},
+		{"7 mod 3", 1},
+		{"-(2)", -2},
+		{"round(2.5) + floor(-1.5) + ceiling(1.2)", 3},
+		{"string-length('héllo')", 5},
+		{"number(//tr[2]/td[2]) div 4", 30},
+	}
+	for _, c := range numbers {
+		got, err := htmls.XPathNumber(root, c.expr)
+		if err != nil || got != c.want {
+			t.Errorf("%q = %v (%v), want %v", c.expr, got, err, c.want)
+		}
+	}
+
+	strs := []struct {
+		expr string
+		want string
+	}{
+		{"//tr[2]/td[1]", "Apple"},
+		{"concat(//th[1], ': ', count(//td) div 2)", "Item: 3"},
+		{"substring('12345', 1.5, 2.6)", "234"},
+		{"substring-before('2022-03-01', '-')", "2022"},
+		{"substring-after('2022-03-01', '-')", "03-01"},
+		{"translate('bar', 'abc', 'AB')", "BAr"},
+		{"name(//*[@lang])", "ul"},
+		{"string(1 div 0)", "Infinity"},
+		{"string(number('abc'))", "NaN"},
+		{"//li[3] = 'third'", "true"},
+		{"//td = 80", "true"},
+		{"//td != //td", "true"},
+		{"not(//table)", "false"},
+	}
+	for _, c := range strs {
+		got, err := htmls.XPathString(root, c.expr)
+		if err != nil || got != c.want {
+			t.Errorf("%q = %q (%v), want %q", c.expr, got, err, c.want)
+		}
+	}
+
+	if f, _ := htmls.XPathNumber(root, "number('1e3')"); !math.IsNaN(f) {
+		t.Error("Expected exponent notation to be NaN")
+	}
+}
+
+func TestXPathRelativeToContextNode(t *testing.T) {
+	root := parseXPathHTML(t)
+
+	ul, _ := htmls.SelectOne(root, "ul")
+	got, err := htmls.XPathNodes(ul, "li/a")
+	if err != nil || len(got) != 2 {
+		t.Fatalf("Expected 2 nodes, got %d (%v)", len(got), err)
+	}
+	if htmls.Attr(got[1], "href") != "/b" {
+		t.Error("Expected the second anchor")
+	}
+
+	got, _ = htmls.XPathNodes(ul, "/html/body/table")
+	if len(got) != 1 {
+		t.Error("Expected absolute path to start at the document")
+	}
+}
+
+func TestXPathErrors(t *testing.T) {
+	root := parseXPathHTML(t)
+
+	for _, expr := range []string{"", "//", "//a[", "foo(", "unknown()", "count()", "$var", "//a/namespace::x", "1 +", "'abc"} {
+		if _, err := htmls.CompileXPath(expr); err == nil {
+			t.Errorf("Expected an error compiling %q", expr)
+		}
+	}
+
+	if _, err := htmls.XPathNodes(root, "count(//a)"); err == nil {
+		t.Error("Expected an error for a number result")
+	}
+	if _, err := htmls.XPathNumber(root, "count('a')"); err == nil {
+		t.Error("Expected an error for count of a string")
+	}
+	if _, err := htmls.XPathNodes(root, "'a'/b"); err == nil {
+		t.Error("Expected an error for a path from a string")
+	}
+}
@@ -0,0 +1,197 @@
+package slices
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNoField happens when a column path does not resolve to an exported field.
+var ErrNoField = errors.New("no such field")
+
+// Format is the output format of WriteTable.
+type Format int
+
+const (
+	// CSV writes comma separated values as described in RFC 4180.
+	CSV Format = iota
+	// TSV writes tab separated values.
+	TSV
+	// Markdown writes a GitHub flavored Markdown table.
+	Markdown
+	// Text writes a plain text table with aligned columns.
+	Text
+)
+
+// Column describes one column of a table.
+type Column struct {
+	// Header is the column title, Path is used when it is empty.
+	Header string
+	// Path is a field name, or a dot separated path into nested structs,
+	// e.g. "Name" or "Address.City". Pointers are followed on the way.
+	Path string
+	// Formatter converts the field value to a cell, fmt.Sprint is used when nil.
+	// A nil pointer along the path is passed as nil.
+	Formatter func(v any) string
+}
+
+// Columns returns a column for each of the provided field paths.
+func Columns(paths ...string) []Column {
+	cols := make([]Column, len(paths))
+	for i, p := range paths {
+		cols[i] = Column{Path: p}
+	}
+	return cols
+}
+
+// ToTable maps the given columns of a slice of structs to a grid of strings.
+// The first row holds the headers.
+func ToTable(slice any, columns ...Column) ([][]string, error) {
+	val := reflect.ValueOf(slice)
+	if val.Kind() != reflect.Slice && val.Kind() != reflect.Array {
+		return nil, ErrNotSlice
+	}
+
+	header := make([]string, len(columns))
+	for i, c := range columns {
+		header[i] = c.Header
+		if header[i] == "" {
+			header[i] = c.Path
+		}
+	}
+
+	rows := make([][]string, 0, val.Len()+1)
+	rows = append(rows, header)
+	for i := 0; i < val.Len(); i++ {
+		row := make([]string, len(columns))
+		for j, c := range columns {
+			v, err := fieldByPath(val.Index(i), c.Path)
+			if err != nil {
+				return nil, errors.Wrapf(err, "row %d", i)
+			}
+			row[j] = formatCell(v, c.Formatter)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// WriteTable writes the given columns of a slice of structs to w in the given format.
+//
+//	err := slices.WriteTable(os.Stdout, people, slices.Markdown,
+//	    slices.Column{Path: "Name"},
+//	    slices.Column{Header: "Balance", Path: "Money", Formatter: func(v any) string {
+//	        return fmt.Sprintf("%.2f", v)
+//	    }},
+//	)
+func WriteTable(w io.Writer, slice any, format Format, columns ...Column) error {
+	rows, err := ToTable(slice, columns...)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case CSV, TSV:
+		cw := csv.NewWriter(w)
+		if format == TSV {
+			cw.Comma = '\t'
+		}
+		if err := cw.WriteAll(rows); err != nil {
+			return err
+		}
+	case Markdown:
+		return writeMarkdown(w, rows)
+	case Text:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		flatten := strings.NewReplacer("\t", " ", "\n", " ")
+		for _, row := range rows {
+			for i := range row {
+				row[i] = flatten.Replace(row[i])
+			}
+			if _, err := fmt.Fprintln(tw, strings.Join(row, "\t")); err != nil {
+				return err
+			}
+		}
+		return tw.Flush()
+	default:
+		return errors.Errorf("unknown format %d", format)
+	}
+	return nil
+}
+
+func writeMarkdown(w io.Writer, rows [][]string) error {
+	escape := strings.NewReplacer("|", `\|`, "\r\n", "<br>", "\n", "<br>")
+	line := func(cells []string) error {
+		for i := range cells {
+			cells[i] = escape.Replace(cells[i])
+		}
+		_, err := fmt.Fprintf(w, "| %s |\n", strings.Join(cells, " | "))
+		return err
+	}
+
+	if err := line(rows[0]); err != nil {
+		return err
+	}
+	sep := make([]string, len(rows[0]))
+	for i := range sep {
+		sep[i] = "---"
+	}
+	if err := line(sep); err != nil {
+		return err
+	}
+	for _, row := range rows[1:] {
+		if err := line(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fieldByPath resolves a dot separated field path, returning nil when a nil
+// pointer, including an embedded one, is met along the way.
+func fieldByPath(v reflect.Value, path string) (any, error) {
+	for _, name := range strings.Split(path, ".") {
+		for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return nil, nil
+			}
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Struct {
+			return nil, errors.Wrap(ErrNoField, path)
+		}
+		f, ok := v.Type().FieldByName(name)
+		if !ok || !f.IsExported() {
+			return nil, errors.Wrap(ErrNoField, path)
+		}
+		fv, err := v.FieldByIndexErr(f.Index)
+		if err != nil {
+			// a field promoted through a nil embedded pointer
+			return nil, nil
+		}
+		v = fv
+	}
+	return v.Interface(), nil
+}
+
+func formatCell(v any, formatter func(any) string) string {
+	if formatter != nil {
+		return formatter(v)
+	}
+	if v == nil {
+		return ""
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return ""
+		}
+		return fmt.Sprint(rv.Elem().Interface())
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,118 @@
+package slices_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/Akagi201/utils-go/slices"
+	"github.com/stretchr/testify/assert"
+)
+
+type Address struct {
+	City string
+}
+
+type Employee struct {
+	Name    string
+	Salary  float64
+	Address *Address
+}
+
+var employees = []Employee{
+	{"George", 4200.5, &Address{"Paris"}},
+	{"Jeff, Jr.", 0, nil},
+	{"Ted|T", 50, &Address{"New\nYork"}},
+}
+
+func TestWriteTableCSV(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	err := slices.WriteTable(&buf, employees, slices.CSV, slices.Columns("Name", "Address.City")...)
+	assert.Nil(err)
+	assert.Equal("Name,Address.City\nGeorge,Paris\n\"Jeff, Jr.\",\nTed|T,\"New\nYork\"\n", buf.String())
+}
+
+func TestWriteTableTSV(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	err := slices.WriteTable(&buf, employees[:2], slices.TSV, slices.Columns("Name", "Salary")...)
+	assert.Nil(err)
+	assert.Equal("Name\tSalary\nGeorge\t4200.5\nJeff, Jr.\t0\n", buf.String())
+}
+
+func TestWriteTableMarkdown(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	err := slices.WriteTable(&buf, employees, slices.Markdown,
+		slices.Column{Header: "Employee", Path: "Name"},
+		slices.Column{Path: "Address.City"},
+	)
+	assert.Nil(err)
+	assert.Equal("| Employee | Address.City |\n"+
+		"| --- | --- |\n"+
+		"| George | Paris |\n"+
+		"| Jeff, Jr. |  |\n"+
+		"| Ted\\|T | New<br>York |\n", buf.String())
+}
+
+func TestWriteTableEmbedded(t *testing.T) {
+	assert := assert.New(t)
+
+	type Manager struct {
+		*Address
+		Name string
+	}
+	managers := []Manager{{&Address{"Paris"}, "George"}, {nil, "Jeff"}}
+
+	var buf bytes.Buffer
+	err := slices.WriteTable(&buf, managers, slices.CSV, slices.Columns("Name", "City")...)
+	assert.Nil(err)
+	assert.Equal("Name,City\nGeorge,Paris\nJeff,\n", buf.String())
+}
+
+func TestWriteTableFormatter(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	err := slices.WriteTable(&buf, employees, slices.CSV, slices.Column{
+		Path: "Salary",
+		Formatter: func(v any) string {
+			return fmt.Sprintf("%.2f", v)
+		},
+	})
+	assert.Nil(err)
+	assert.Equal("Salary\n4200.50\n0.00\n50.00\n", buf.String())
+}
+
+func TestWriteTableErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	err := slices.WriteTable(&buf, employees[0], slices.CSV, slices.Columns("Name")...)
+	assert.Equal(slices.ErrNotSlice, err)
+
+	err = slices.WriteTable(&buf, employees, slices.CSV, slices.Columns("Age")...)
+	assert.True(errors.Is(err, slices.ErrNoField))
+
+	err = slices.WriteTable(&buf, employees, slices.CSV, slices.Columns("Name.First")...)
+	assert.True(errors.Is(err, slices.ErrNoField))
+}
+
+func ExampleWriteTable() {
+	people := []*Person{
+		{0, "George", 42.42, true},
+		{1, "Jeff", 0, true},
+	}
+	_ = slices.WriteTable(os.Stdout, people, slices.Text, slices.Columns("ID", "Name", "Money")...)
+
+	// Output:
+	// ID  Name    Money
+	// 0   George  42.42
+	// 1   Jeff    0
+}